@@ -0,0 +1,369 @@
+// Package apiclient 为 /api/v1 接口的 Go 客户端, 类型与 /openapi.json 中的定义一致
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 交易对信息
+type Symbol struct {
+	Symbol      string  `json:"symbol"`
+	ClientId    string  `json:"client_id"`
+	Paused      bool    `json:"paused"`
+	BucketSize  int64   `json:"bucket_size"`  // 数据流时长(单位: 秒)
+	GracePeriod int64   `json:"grace_period"` // 迟到成交的宽限时间(单位: 毫秒)
+	Windows     []int64 `json:"windows"`      // 数据区间时长(单位: 秒)
+}
+
+// 新增订阅参数
+type AddSymbol struct {
+	Symbol   string `json:"symbol"`
+	ClientId string `json:"client_id,omitempty"` // 默认与交易对相同
+	Paused   bool   `json:"paused,omitempty"`    // 添加后暂不订阅
+}
+
+// 数据区间, 时间单位为秒. 查询时指定了 Fields 的情况下未返回的字段为 0
+type Section struct {
+	ID           int64 `json:"id"`
+	EndTime      int64 `json:"end_time"`
+	Buy10        int64 `json:"buy10"`
+	Sell10       int64 `json:"sell10"`
+	Inflow10     int64 `json:"inflow10"`
+	Buy30        int64 `json:"buy30"`
+	Sell30       int64 `json:"sell30"`
+	Inflow30     int64 `json:"inflow30"`
+	Buy60        int64 `json:"buy60"`
+	Sell60       int64 `json:"sell60"`
+	Inflow60     int64 `json:"inflow60"`
+	Buy300       int64 `json:"buy300"`
+	Sell300      int64 `json:"sell300"`
+	Inflow300    int64 `json:"inflow300"`
+	Buy900       int64 `json:"buy900"`
+	Sell900      int64 `json:"sell900"`
+	Inflow900    int64 `json:"inflow900"`
+	Buy3600      int64 `json:"buy3600"`
+	Sell3600     int64 `json:"sell3600"`
+	Inflow3600   int64 `json:"inflow3600"`
+	Buy14400     int64 `json:"buy14400"`
+	Sell14400    int64 `json:"sell14400"`
+	Inflow14400  int64 `json:"inflow14400"`
+	BucketBuy    int64 `json:"bucket_buy"` // 结束的数据流
+	BucketSell   int64 `json:"bucket_sell"`
+	BucketInflow int64 `json:"bucket_inflow"`
+	Partial      bool  `json:"partial"`    // 存在未完整覆盖的数据区间
+	Backfilled   bool  `json:"backfilled"` // 结束的数据流包含补全的成交
+}
+
+// 分页信息
+type Meta struct {
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+// 数据区间查询结果
+type SectionPage struct {
+	Sections []*Section
+	Meta     *Meta
+}
+
+// 写入器状态
+type WriterStats struct {
+	Queued   int   `json:"queued"`
+	Capacity int   `json:"capacity"`
+	Written  int64 `json:"written"`
+	Dropped  int64 `json:"dropped"`
+	Failed   int64 `json:"failed"`
+	Spooled  int64 `json:"spooled"`
+}
+
+// 实时数据区间
+type LiveWindow struct {
+	Duration  int64 `json:"duration"`
+	Buy       int64 `json:"buy"`
+	Sell      int64 `json:"sell"`
+	Inflow    int64 `json:"inflow"`
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+	Partial   bool  `json:"partial"`
+}
+
+// 正在累计的数据流, Timestamp 为按数据流时长对齐的开始时间
+type LiveFlow struct {
+	Buy       int64 `json:"buy"`
+	Sell      int64 `json:"sell"`
+	Inflow    int64 `json:"inflow"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// 交易对实时数据
+type Live struct {
+	Symbol  string        `json:"symbol"`
+	Windows []*LiveWindow `json:"windows"`
+	Flow    *LiveFlow     `json:"flow"`  // 最新的数据流
+	Flows   []*LiveFlow   `json:"flows"` // 所有正在累计的数据流
+}
+
+// 数据区间查询参数, 零值表示使用服务端默认值
+type SectionQuery struct {
+	Start    int64    // 结束时间不小于 Start(单位: 秒)
+	End      int64    // 结束时间小于 End(单位: 秒)
+	Limit    int      // 默认 100, 最大 1000
+	Offset   int      //
+	Desc     bool     // 按结束时间倒序
+	Fields   []string // 返回的字段, 默认返回全部字段
+	Complete bool     // 只返回数据完整(partial 为 false)的数据区间
+}
+
+func (q *SectionQuery) values() url.Values {
+	vs := url.Values{}
+	if q == nil {
+		return vs
+	}
+	if q.Start > 0 {
+		vs.Set("start", strconv.FormatInt(q.Start, 10))
+	}
+	if q.End > 0 {
+		vs.Set("end", strconv.FormatInt(q.End, 10))
+	}
+	if q.Limit > 0 {
+		vs.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		vs.Set("offset", strconv.Itoa(q.Offset))
+	}
+	if q.Desc {
+		vs.Set("order", "desc")
+	}
+	if len(q.Fields) > 0 {
+		vs.Set("fields", strings.Join(q.Fields, ","))
+	}
+	if q.Complete {
+		vs.Set("complete", "true")
+	}
+	return vs
+}
+
+// 导出参数, 零值表示使用服务端默认值
+type ExportQuery struct {
+	Table  string // sections(默认), trades
+	Format string // csv(默认), parquet
+	Start  int64  // 开始时间(单位: 秒)
+	End    int64  // 结束时间(单位: 秒)
+}
+
+func (q *ExportQuery) values() url.Values {
+	vs := url.Values{}
+	if q == nil {
+		return vs
+	}
+	if q.Table != "" {
+		vs.Set("table", q.Table)
+	}
+	if q.Format != "" {
+		vs.Set("format", q.Format)
+	}
+	if q.Start > 0 {
+		vs.Set("start", strconv.FormatInt(q.Start, 10))
+	}
+	if q.End > 0 {
+		vs.Set("end", strconv.FormatInt(q.End, 10))
+	}
+	return vs
+}
+
+// 接口返回的错误
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("huobi api: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+type response struct {
+	Data  json.RawMessage `json:"data"`
+	Meta  *Meta           `json:"meta"`
+	Error *Error          `json:"error"`
+}
+
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// 创建客户端, baseURL 为服务地址, 如 http://127.0.0.1:9879, httpClient 为 nil 时使用 http.DefaultClient
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), http: httpClient}
+}
+
+// 设置 API key, 服务端开启认证时需要
+func (c *Client) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+// 订阅的交易对列表
+func (c *Client) Symbols(ctx context.Context) ([]*Symbol, error) {
+	var symbols []*Symbol
+	_, err := c.get(ctx, "/api/v1/symbols", nil, &symbols)
+	return symbols, err
+}
+
+// 交易对信息
+func (c *Client) Symbol(ctx context.Context, symbol string) (*Symbol, error) {
+	s := &Symbol{}
+	_, err := c.get(ctx, symbolPath(symbol, ""), nil, s)
+	return s, err
+}
+
+// 查询数据区间
+func (c *Client) Sections(ctx context.Context, symbol string, q *SectionQuery) (*SectionPage, error) {
+	page := &SectionPage{}
+	meta, err := c.get(ctx, symbolPath(symbol, "/sections"), q.values(), &page.Sections)
+	page.Meta = meta
+	return page, err
+}
+
+// 符合条件的数据区间总数, 忽略分页及排序参数
+func (c *Client) CountSections(ctx context.Context, symbol string, q *SectionQuery) (int64, error) {
+	var total int64
+	_, err := c.get(ctx, symbolPath(symbol, "/sections/count"), q.values(), &total)
+	return total, err
+}
+
+// 写入器状态, 键为 sections 或 trades
+func (c *Client) WriterStats(ctx context.Context, symbol string) (map[string]*WriterStats, error) {
+	var stats map[string]*WriterStats
+	_, err := c.get(ctx, symbolPath(symbol, "/writer-stats"), nil, &stats)
+	return stats, err
+}
+
+// 内存中的实时数据
+func (c *Client) Live(ctx context.Context, symbol string) (*Live, error) {
+	live := &Live{}
+	_, err := c.get(ctx, symbolPath(symbol, "/live"), nil, live)
+	return live, err
+}
+
+// 新增订阅, 需要 admin 权限
+func (c *Client) AddSymbol(ctx context.Context, params *AddSymbol) (*Symbol, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	s := &Symbol{}
+	_, err = c.send(ctx, http.MethodPost, "/api/v1/symbols", nil, body, s)
+	return s, err
+}
+
+// 删除订阅, 需要 admin 权限
+func (c *Client) RemoveSymbol(ctx context.Context, symbol string) (*Symbol, error) {
+	s := &Symbol{}
+	_, err := c.send(ctx, http.MethodDelete, symbolPath(symbol, ""), nil, nil, s)
+	return s, err
+}
+
+// 暂停订阅, 需要 admin 权限
+func (c *Client) PauseSymbol(ctx context.Context, symbol string) (*Symbol, error) {
+	s := &Symbol{}
+	_, err := c.send(ctx, http.MethodPost, symbolPath(symbol, "/pause"), nil, nil, s)
+	return s, err
+}
+
+// 恢复订阅, 需要 admin 权限
+func (c *Client) ResumeSymbol(ctx context.Context, symbol string) (*Symbol, error) {
+	s := &Symbol{}
+	_, err := c.send(ctx, http.MethodPost, symbolPath(symbol, "/resume"), nil, nil, s)
+	return s, err
+}
+
+// 导出数据, 调用方需关闭返回的数据流
+func (c *Client) Export(ctx context.Context, symbol string, q *ExportQuery) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, symbolPath(symbol, "/export"), q.values(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		_, err = decode(resp, nil)
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func symbolPath(symbol, suffix string) string {
+	return "/api/v1/symbols/" + url.PathEscape(symbol) + suffix
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return c.http.Do(req)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, data interface{}) (*Meta, error) {
+	return c.send(ctx, http.MethodGet, path, query, nil, data)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte, data interface{}) (*Meta, error) {
+	resp, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decode(resp, data)
+}
+
+// 解析统一响应格式, 非 2xx 响应返回 *Error
+func decode(resp *http.Response, data interface{}) (*Meta, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	r := &response{}
+	err = json.Unmarshal(body, r)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if err != nil || r.Error == nil {
+			return nil, &Error{Status: resp.StatusCode, Code: http.StatusText(resp.StatusCode), Message: string(body)}
+		}
+		r.Error.Status = resp.StatusCode
+		return nil, r.Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data != nil && len(r.Data) > 0 {
+		err = json.Unmarshal(r.Data, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.Meta, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/morgine/pkg/config"
+	config2 "huobi/config"
+	"huobi/model"
+	"huobi/routes"
+	"strconv"
+	"time"
+)
+
+// 管理 API key:
+//
+//	apikey create -name dashboard [-scope read|admin] [-rate 10]  创建 key, 明文 key 仅输出一次
+//	apikey list                                                  查看所有 key
+//	apikey revoke <id>                                           吊销 key
+func runAPIKey(configs config.Configs, args []string) error {
+	if len(args) == 0 {
+		return errors.New("apikey: missing action, expected create, list or revoke")
+	}
+	storageConfig, err := config2.NewStorage("storage", configs)
+	if err != nil {
+		return err
+	}
+	if storageConfig.Driver == config2.StorageMemory {
+		return errors.New("apikey: storage driver memory does not persist api keys")
+	}
+	shared, err := routes.NewSharedStorage(storageConfig, configs)
+	if err != nil {
+		return err
+	}
+	defer shared.Close()
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := fs.String("name", "", "key 名称")
+		scope := fs.String("scope", model.ScopeRead, "权限范围: read, admin")
+		rate := fs.Int("rate", 0, "每秒允许的请求数, 为 0 时使用默认配置")
+		err = fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		if *name == "" {
+			return errors.New("apikey create: -name is required")
+		}
+		if !model.ValidScope(*scope) {
+			return fmt.Errorf("apikey create: unsupported scope %q", *scope)
+		}
+		if *rate < 0 {
+			return fmt.Errorf("apikey create: invalid rate %d", *rate)
+		}
+		key, record, err := model.NewAPIKey(*name, *scope, *rate)
+		if err != nil {
+			return err
+		}
+		err = shared.CreateAPIKey(record)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created api key %d (%s, %s): %s\n", record.ID, record.Name, record.Scope, key)
+		fmt.Println("store it now, the key cannot be shown again")
+		return nil
+	case "list":
+		keys, err := shared.ListAPIKeys()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			status := "active"
+			if k.RevokedAt > 0 {
+				status = "revoked at " + time.Unix(k.RevokedAt, 0).Format(time.RFC3339)
+			}
+			fmt.Printf("%d\t%s\t%s...\t%s\trate=%d\tcreated at %s\t%s\n", k.ID, k.Name, k.Prefix, k.Scope, k.RateLimit,
+				time.Unix(k.CreatedAt, 0).Format(time.RFC3339), status)
+		}
+		return nil
+	case "revoke":
+		if len(args) < 2 {
+			return errors.New("apikey revoke: missing id")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("apikey revoke: invalid id %q", args[1])
+		}
+		err = shared.RevokeAPIKey(id)
+		if err != nil {
+			return fmt.Errorf("apikey revoke %d: %w", id, err)
+		}
+		fmt.Printf("revoked api key %d\n", id)
+		return nil
+	default:
+		return fmt.Errorf("unknown apikey action %q", args[0])
+	}
+}
@@ -3,15 +3,16 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/huobirdcenter/huobi_golang/logging/applogger"
-	"github.com/morgine/pkg/config"
-	"go.uber.org/zap/zapcore"
+	config2 "huobi/config"
 	"huobi/routes"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -20,54 +21,98 @@ func main() {
 	// 加载配置文件
 	configFile := flag.String("c", "config.toml", "配置文件")
 	addr := flag.String("a", ":8886", "监听地址")
+	var sets setFlags
+	flag.Var(&sets, "set", "覆盖配置项, 如 -set postgres.password=123456, 可重复使用, 优先于环境变量 HUOBI_POSTGRES_PASSWORD")
 	flag.Parse()
 
+	// 初始化配置服务, 配置文件中的配置项可被环境变量及 -set 覆盖
+	var configs, err = config2.Load(*configFile, sets)
+	if err != nil {
+		panic(err)
+	}
+
+	// 执行子命令, 如: migrate up
+	if flag.NArg() > 0 {
+		err = runCommand(configs, flag.Args())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	engine := gin.New()
 
-	engine.Use(gin.Logger())
+	// 请求日志隐藏 api_key 查询参数
+	engine.Use(routes.Logger())
 
 	engine.Use(gin.Recovery())
 
+	corsConfig, err := config2.NewCors("cors", configs)
+	if err != nil {
+		panic(err)
+	}
 	engine.Use(cors.New(cors.Config{
-		// Set cors and db middleware
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"*"},
-		AllowHeaders:     []string{"*"},
-		AllowCredentials: true,
+		// 浏览器不接受允许所有来源且携带凭证的响应, 允许所有来源时不允许携带凭证
+		AllowAllOrigins:  corsConfig.AllowAll(),
+		AllowOrigins:     allowOrigins(corsConfig),
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
+		AllowCredentials: corsConfig.AllowCredentials && !corsConfig.AllowAll(),
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// 初始化配置服务
-	var configs, err = config.UnmarshalFile(*configFile)
+	logConfig, err := config2.NewLog("log", configs)
 	if err != nil {
 		panic(err)
 	}
-	clients := routes.RegisterRoutes(engine, configs)
+	applogger.SetLevel(logConfig.ZapLevel())
 
-	var closeFuncs []func()
+	subscribe, reload, closeAll := routes.RegisterRoutes(engine, configs)
 
-	for _, client := range clients {
-		closeFuncs = append(closeFuncs, client.Subscribe())
-	}
-	defer func() {
-		for _, closeFunc := range closeFuncs {
-			closeFunc()
-		}
-	}()
+	subscribe()
+
+	// 收到 SIGHUP 或配置文件修改后重新加载日志及订阅配置
+	go watchConfig(*configFile, func() {
+		reloadConfig(*configFile, sets, reload)
+	})
+
+	// 订阅关闭后不再产生新数据, 将队列中剩余数据写入数据库
+	defer closeAll()
 	serveHttp(*addr, engine)
 }
 
+// 可重复使用的 -set 参数
+type setFlags []string
+
+func (s *setFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func allowOrigins(c *config2.Cors) []string {
+	if c.AllowAll() {
+		return nil
+	}
+	return c.AllowOrigins
+}
+
 func serveHttp(addr string, engine *gin.Engine) {
 	// 开启服务
+	// 数据导出及推送接口需要长时间输出数据, 每次写入前通过 ConnContext 保存的连接延长写入超时
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      engine,
 		ReadTimeout:  180 * time.Second,
 		WriteTimeout: 180 * time.Second,
+		ConnContext:  routes.ConnContext,
 	}
 
 	applogger.Info("listen and serve http://localhost%s/queue", addr)
-	applogger.SetLevel(zapcore.InfoLevel)
 
 	// Initializing the server in a goroutine so that
 	// it won't block the graceful shutdown handling below
@@ -79,7 +124,7 @@ func serveHttp(addr string, engine *gin.Engine) {
 
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscall.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"github.com/morgine/pkg/config"
+)
+
+// 执行子命令
+func runCommand(configs config.Configs, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(configs, args[1:])
+	case "export":
+		return runExport(configs, args[1:])
+	case "import":
+		return runImport(configs, args[1:])
+	case "apikey":
+		return runAPIKey(configs, args[1:])
+	case "config":
+		return runConfig(configs, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
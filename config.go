@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/morgine/pkg/config"
+	config2 "huobi/config"
+	"os"
+)
+
+// 配置命令:
+//
+//	config print  输出合并环境变量及 -set 参数后生效的配置, 密码等敏感配置项以 ****** 代替
+func runConfig(configs config.Configs, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: config print")
+	}
+	err := toml.NewEncoder(os.Stdout).Encode(config2.Redacted(configs))
+	if err != nil {
+		return fmt.Errorf("config print: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"github.com/morgine/pkg/config"
+)
+
+// 接口认证配置
+type Auth struct {
+	Enabled   bool `toml:"enabled"`    // 是否开启 API key 认证, 关闭时所有接口公开访问
+	RateLimit int  `toml:"rate_limit"` // 每个 API key 每秒允许的请求数, 为 0 时不限制
+	Burst     int  `toml:"burst"`      // 允许的突发请求数
+}
+
+// 加载接口认证配置, 未配置时不开启认证
+func NewAuth(namespace string, configs config.Configs) (*Auth, error) {
+	cfg := &Auth{}
+	if configs[namespace] != nil {
+		err := configs.UnmarshalSub(namespace, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RateLimit < 0 {
+		return nil, fmt.Errorf("config.NewAuth: invalid rate_limit %d", cfg.RateLimit)
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return cfg, nil
+}
+
+// 跨域配置
+type Cors struct {
+	AllowOrigins     []string `toml:"allow_origins"`     // 允许的来源, "*" 表示允许所有来源
+	AllowCredentials bool     `toml:"allow_credentials"` // 是否允许携带凭证, 允许所有来源时无效
+}
+
+// 加载跨域配置, 未配置时允许所有来源且不允许携带凭证
+func NewCors(namespace string, configs config.Configs) (*Cors, error) {
+	cfg := &Cors{}
+	if configs[namespace] != nil {
+		err := configs.UnmarshalSub(namespace, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.AllowOrigins) == 0 {
+		cfg.AllowOrigins = []string{"*"}
+	}
+	return cfg, nil
+}
+
+// 是否允许所有来源
+func (c *Cors) AllowAll() bool {
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
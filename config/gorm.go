@@ -4,7 +4,10 @@ import (
 	"github.com/morgine/pkg/config"
 	"github.com/morgine/pkg/database/orm"
 	"github.com/morgine/pkg/database/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"os"
+	"path/filepath"
 )
 
 // 重写 table prefix
@@ -21,3 +24,33 @@ func NewPostgresORM(postgresNamespace, gormNamespace, symbol string, configs con
 	gormConfig.TablePrefix = symbol + "_"
 	return gormConfig.Init(orm.NewPostgresDialector(db))
 }
+
+// sqlite 数据库配置
+//
+//	[sqlite]
+//	# 数据库文件
+//	file = "data/huobi.db"
+type Sqlite struct {
+	File string `toml:"file"`
+}
+
+// 嵌入式 sqlite 数据库, 所有交易对共用同一个数据库文件, 以表名前缀区分
+func NewSqliteORM(sqliteNamespace, gormNamespace, symbol string, configs config.Configs) (*gorm.DB, error) {
+	cfg := &Sqlite{}
+	err := configs.UnmarshalSub(sqliteNamespace, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(cfg.File), 0755)
+	if err != nil {
+		return nil, err
+	}
+	gormConfig := &orm.Config{}
+	err = configs.UnmarshalSub(gormNamespace, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+	gormConfig.TablePrefix = symbol + "_"
+	// 多个连接同时写入时等待锁释放, 而不是立即返回 database is locked
+	return gormConfig.Init(sqlite.Open(cfg.File + "?_busy_timeout=5000&_journal_mode=WAL"))
+}
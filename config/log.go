@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"github.com/morgine/pkg/config"
+	"go.uber.org/zap/zapcore"
+)
+
+// 日志配置
+type Log struct {
+	Level string `toml:"level"` // 日志等级: debug, info, warn, error
+}
+
+// 加载日志配置, 未配置时日志等级为 info
+func NewLog(namespace string, configs config.Configs) (*Log, error) {
+	cfg := &Log{}
+	if configs[namespace] != nil {
+		err := configs.UnmarshalSub(namespace, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Level == "" {
+		cfg.Level = "info"
+	}
+	var level zapcore.Level
+	err := level.UnmarshalText([]byte(cfg.Level))
+	if err != nil {
+		return nil, fmt.Errorf("config.NewLog: invalid level %q", cfg.Level)
+	}
+	return cfg, nil
+}
+
+// 日志等级, 配置已在 NewLog 中校验
+func (l *Log) ZapLevel() zapcore.Level {
+	var level zapcore.Level
+	_ = level.UnmarshalText([]byte(l.Level))
+	return level
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"github.com/morgine/pkg/config"
+	"strings"
+	"time"
+)
+
+// 行情 websocket 连接配置, [[subscriptions]] 中的 market 可覆盖其中的任意项
+//
+//	[market]
+//	scheme = "wss"
+//	host = "api.huobi.pro"
+//	path = "/ws"
+//	ping_timeout = 60000
+//	pong_timeout = 10000
+//	reconnect_min = 1000
+//	reconnect_max = 60000
+//	auto_reconnect = true
+//	backfill = true
+//	rest_url = "https://api.huobi.pro"
+//	backfill_size = 2000
+//	backfill_trade_id = false
+type Market struct {
+	Scheme          string `toml:"scheme"`            // ws 或 wss
+	Host            string `toml:"host"`              // 行情服务地址, 可包含端口
+	Path            string `toml:"path"`              // websocket 路径
+	PingTimeout     int    `toml:"ping_timeout"`      // 超过该时间未收到服务端的 ping 或推送时断开重连(单位: 毫秒)
+	PongTimeout     int    `toml:"pong_timeout"`      // 回复 pong 及发送订阅请求的超时时间(单位: 毫秒)
+	ReconnectMin    int    `toml:"reconnect_min"`     // 首次重连的等待时间, 之后每次失败加倍(单位: 毫秒)
+	ReconnectMax    int    `toml:"reconnect_max"`     // 重连的最长等待时间(单位: 毫秒)
+	AutoReconnect   *bool  `toml:"auto_reconnect"`    // 连接断开后是否自动重连
+	Backfill        *bool  `toml:"backfill"`          // 连接成功后是否通过 REST 接口补全缺失的成交
+	RestURL         string `toml:"rest_url"`          // REST 接口地址, 请求超时时间为 pong_timeout
+	BackfillSize    int    `toml:"backfill_size"`     // 补全时获取的最近成交数量, 最大 2000
+	BackfillTradeId *bool  `toml:"backfill_trade_id"` // 推送的成交 id 不连续时是否补全, 需成交 id 连续递增, 火币未保证这一点, 默认关闭
+}
+
+// 默认的行情 websocket 连接配置
+func DefaultMarket() *Market {
+	autoReconnect, backfill, backfillTradeId := true, true, false
+	return &Market{
+		Scheme:          "wss",
+		Host:            "api.huobi.pro",
+		Path:            "/ws",
+		PingTimeout:     60000,
+		PongTimeout:     10000,
+		ReconnectMin:    1000,
+		ReconnectMax:    60000,
+		AutoReconnect:   &autoReconnect,
+		Backfill:        &backfill,
+		RestURL:         "https://api.huobi.pro",
+		BackfillSize:    2000,
+		BackfillTradeId: &backfillTradeId,
+	}
+}
+
+// 加载行情 websocket 连接配置, 未配置的项使用默认值
+func NewMarket(namespace string, configs config.Configs) (*Market, error) {
+	cfg := &Market{}
+	if configs[namespace] != nil {
+		err := configs.UnmarshalSub(namespace, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cfg = DefaultMarket().merge(cfg)
+	err := cfg.validate()
+	if err != nil {
+		return nil, fmt.Errorf("config.NewMarket: %w", err)
+	}
+	return cfg, nil
+}
+
+// 使用 override 中已配置的项覆盖当前配置, 返回新的配置
+func (m *Market) merge(override *Market) *Market {
+	merged := *m
+	if override == nil {
+		return &merged
+	}
+	if override.Scheme != "" {
+		merged.Scheme = override.Scheme
+	}
+	if override.Host != "" {
+		merged.Host = override.Host
+	}
+	if override.Path != "" {
+		merged.Path = override.Path
+	}
+	if override.PingTimeout != 0 {
+		merged.PingTimeout = override.PingTimeout
+	}
+	if override.PongTimeout != 0 {
+		merged.PongTimeout = override.PongTimeout
+	}
+	if override.ReconnectMin != 0 {
+		merged.ReconnectMin = override.ReconnectMin
+	}
+	if override.ReconnectMax != 0 {
+		merged.ReconnectMax = override.ReconnectMax
+	}
+	if override.AutoReconnect != nil {
+		autoReconnect := *override.AutoReconnect
+		merged.AutoReconnect = &autoReconnect
+	}
+	if override.Backfill != nil {
+		backfill := *override.Backfill
+		merged.Backfill = &backfill
+	}
+	if override.RestURL != "" {
+		merged.RestURL = override.RestURL
+	}
+	if override.BackfillSize != 0 {
+		merged.BackfillSize = override.BackfillSize
+	}
+	if override.BackfillTradeId != nil {
+		backfillTradeId := *override.BackfillTradeId
+		merged.BackfillTradeId = &backfillTradeId
+	}
+	return &merged
+}
+
+func (m *Market) validate() error {
+	if m.Scheme != "ws" && m.Scheme != "wss" {
+		return fmt.Errorf("market scheme must be ws or wss, got %q", m.Scheme)
+	}
+	if m.PingTimeout < 0 || m.PongTimeout < 0 || m.ReconnectMin < 0 || m.ReconnectMax < 0 {
+		return fmt.Errorf("market timeouts must not be negative")
+	}
+	if m.ReconnectMax < m.ReconnectMin {
+		return fmt.Errorf("market reconnect_max %d is less than reconnect_min %d", m.ReconnectMax, m.ReconnectMin)
+	}
+	if m.BackfillSize < 1 || m.BackfillSize > 2000 {
+		return fmt.Errorf("market backfill_size must be in [1, 2000], got %d", m.BackfillSize)
+	}
+	if !strings.HasPrefix(m.RestURL, "http://") && !strings.HasPrefix(m.RestURL, "https://") {
+		return fmt.Errorf("market rest_url must start with http:// or https://, got %q", m.RestURL)
+	}
+	return nil
+}
+
+// 连接断开后是否自动重连
+func (m *Market) Reconnect() bool {
+	return m.AutoReconnect == nil || *m.AutoReconnect
+}
+
+// 是否通过 REST 接口补全缺失的成交
+func (m *Market) BackfillEnabled() bool {
+	return m.Backfill == nil || *m.Backfill
+}
+
+// 推送的成交 id 不连续时是否补全
+func (m *Market) BackfillOnTradeIdGap() bool {
+	return m.BackfillTradeId != nil && *m.BackfillTradeId
+}
+
+// websocket 地址
+func (m *Market) URL() string {
+	return m.Scheme + "://" + m.Host + m.Path
+}
+
+func (m *Market) PingTimeoutDuration() time.Duration {
+	return time.Duration(m.PingTimeout) * time.Millisecond
+}
+
+func (m *Market) PongTimeoutDuration() time.Duration {
+	return time.Duration(m.PongTimeout) * time.Millisecond
+}
+
+func (m *Market) ReconnectMinDuration() time.Duration {
+	return time.Duration(m.ReconnectMin) * time.Millisecond
+}
+
+func (m *Market) ReconnectMaxDuration() time.Duration {
+	return time.Duration(m.ReconnectMax) * time.Millisecond
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"github.com/morgine/pkg/config"
+	"github.com/morgine/pkg/database/orm"
+	"github.com/morgine/pkg/database/postgres"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 环境变量前缀, 如 postgres.password 对应 HUOBI_POSTGRES_PASSWORD
+const EnvPrefix = "HUOBI"
+
+// 可覆盖的配置项, 键为命名空间, 值为对应的配置结构体. [[subscriptions]] 为数组, 不支持覆盖
+var namespaces = map[string]interface{}{
+	"storage":  Storage{},
+	"sqlite":   Sqlite{},
+	"postgres": postgres.Config{},
+	"gorm":     orm.Config{},
+	"writer":   Writer{},
+	"auth":     Auth{},
+	"cors":     Cors{},
+	"log":      Log{},
+	"market":   Market{},
+	"server":   Server{},
+}
+
+// 名称包含以下内容的配置项在输出时隐藏
+var secretKeys = []string{"password", "secret", "token"}
+
+// 加载配置文件, 依次使用环境变量及 sets 覆盖配置项, sets 的格式为 "namespace.key=value"
+func Load(file string, sets []string) (config.Configs, error) {
+	configs, err := config.UnmarshalFile(file)
+	if err != nil {
+		return nil, err
+	}
+	err = ApplyEnv(configs, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		kv := strings.SplitN(set, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("config.Load: invalid override %q, expected \"namespace.key=value\"", set)
+		}
+		err = Set(configs, kv[0], kv[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return configs, nil
+}
+
+// 使用环境变量覆盖配置项, 环境变量名为 HUOBI_<命名空间>_<配置项>, 均为大写
+func ApplyEnv(configs config.Configs, lookup func(key string) (string, bool)) error {
+	for namespace, schema := range namespaces {
+		for key := range fieldKinds(reflect.TypeOf(schema)) {
+			env := strings.ToUpper(EnvPrefix + "_" + namespace + "_" + key)
+			value, ok := lookup(env)
+			if !ok {
+				continue
+			}
+			err := Set(configs, namespace+"."+key, value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 覆盖配置项, name 的格式为 "namespace.key", value 按配置项的类型解析, 数组以逗号分隔
+func Set(configs config.Configs, name, value string) error {
+	ss := strings.SplitN(name, ".", 2)
+	schema, ok := namespaces[ss[0]]
+	if len(ss) != 2 || !ok {
+		return fmt.Errorf("config.Set: unknown config key %q", name)
+	}
+	kind, ok := fieldKinds(reflect.TypeOf(schema))[ss[1]]
+	if !ok {
+		return fmt.Errorf("config.Set: unknown config key %q", name)
+	}
+	v, err := parseValue(kind, value)
+	if err != nil {
+		return fmt.Errorf("config.Set: %s: %w", name, err)
+	}
+	sub, ok := configs[ss[0]].(map[string]interface{})
+	if !ok {
+		sub = make(map[string]interface{})
+		configs[ss[0]] = sub
+	}
+	sub[ss[1]] = v
+	return nil
+}
+
+// 配置结构体中各配置项的类型, 包含嵌入结构体的字段
+func fieldKinds(t reflect.Type) map[string]reflect.Type {
+	kinds := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for key, kind := range fieldKinds(field.Type) {
+				kinds[key] = kind
+			}
+			continue
+		}
+		if key := field.Tag.Get("toml"); key != "" && key != "-" {
+			kinds[key] = field.Type
+		}
+	}
+	return kinds
+}
+
+func parseValue(t reflect.Type, value string) (interface{}, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	case reflect.Ptr:
+		return parseValue(t.Elem(), value)
+	case reflect.Slice:
+		var vs []interface{}
+		for _, item := range strings.Split(value, ",") {
+			v, err := parseValue(t.Elem(), strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			vs = append(vs, v)
+		}
+		return vs, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// 隐藏敏感配置项后的副本, 用于输出配置
+func Redacted(configs config.Configs) config.Configs {
+	return redactMap(configs)
+}
+
+func redactMap(m map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		redacted[key] = redactValue(key, value)
+	}
+	return redacted
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactMap(v)
+	case []map[string]interface{}:
+		vs := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			vs[i] = redactMap(item)
+		}
+		return vs
+	}
+	if isSecret(key) && !reflect.ValueOf(value).IsZero() {
+		return "******"
+	}
+	return value
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
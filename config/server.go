@@ -1,24 +1,241 @@
 package config
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"github.com/morgine/pkg/config"
+	"regexp"
+	"strings"
+)
 
+// 旧版订阅配置, 格式为 "symbol:clientId,symbol:clientId", 建议改用 [[subscriptions]]
 type Server struct {
 	Subscribes string `toml:"subscribes"`
 }
 
-func (c *Server) InitSubscribes() (subs []Subscribe) {
-	configs := strings.Split(strings.Replace(c.Subscribes, " ", "", -1), ",")
-	for _, config := range configs {
-		ss := strings.Split(config, ":")
-		subs = append(subs, Subscribe{
-			Symbol:   ss[0],
-			ClientId: ss[1],
-		})
+// 解析旧版订阅配置, client id 可省略
+func (c *Server) parseSubscribes() (subs []*subscribeConfig, err error) {
+	s := strings.Replace(c.Subscribes, " ", "", -1)
+	if s == "" {
+		return nil, nil
 	}
-	return
+	for _, item := range strings.Split(s, ",") {
+		ss := strings.Split(item, ":")
+		if len(ss) > 2 || ss[0] == "" {
+			return nil, fmt.Errorf("server.subscribes: invalid item %q, expected \"symbol:clientId\"", item)
+		}
+		sub := &subscribeConfig{Symbol: ss[0]}
+		if len(ss) == 2 {
+			sub.ClientId = ss[1]
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// 支持的数据区间时长(单位: 秒), 与数据区间表的列一致
+var SupportedWindows = []int64{10, 30, 60, 300, 900, 3600, 14400}
+
+// 默认数据流时长(单位: 秒)
+const DefaultBucketSize = 10
+
+// 默认迟到成交的宽限时间(单位: 毫秒)
+const DefaultGracePeriod = 1000
+
+// 交易对同时用作数据表名前缀, 只允许小写字母及数字
+var SymbolPattern = regexp.MustCompile(`^[a-z0-9]{2,32}$`)
+
+// 共享数据表的表名前缀, 不可用作交易对
+const SharedPrefix = "shared"
+
+func ValidSymbol(symbol string) bool {
+	return symbol != SharedPrefix && SymbolPattern.MatchString(symbol)
 }
 
+// 交易对订阅配置
 type Subscribe struct {
-	Symbol   string `toml:"symbol"`
-	ClientId string `toml:"client_id"`
+	Symbol      string
+	ClientId    string   // 订阅请求的 id, 默认与交易对相同
+	BucketSize  int64    // 数据流时长(单位: 秒), 每个数据流结束时生成一条数据区间
+	GracePeriod int64    // 数据流结束后等待迟到成交的时间(单位: 毫秒), 超过该时间到达的成交计入之后的数据流
+	Windows     []int64  // 数据区间时长(单位: 秒), 未包含的时长在数据区间中为 0
+	Storage     *Storage // 存储配置
+	Market      *Market  // 行情 websocket 连接配置
+	Enabled     bool
+}
+
+// 使用默认配置的订阅, 用于通过管理接口新增的订阅. storage 及 market 为全局配置, market 为 nil 时使用默认配置
+func NewSubscribe(symbol, clientId string, storage *Storage, market *Market) Subscribe {
+	sub := &subscribeConfig{Symbol: symbol, ClientId: clientId}
+	return sub.resolve(storage, market)
+}
+
+// 配置文件中的订阅, 未配置的项为零值
+//
+//	[[subscriptions]]
+//	symbol = "btcusdt"
+//	# 以下均可省略
+//	client_id = "1602"
+//	bucket_size = 10
+//	grace_period = 1000
+//	windows = [10, 30, 60, 300, 900, 3600, 14400]
+//	storage = "postgres"
+//	trades = false
+//	enabled = true
+//	# 覆盖 [market] 中的配置项
+//	market = { host = "api-aws.huobi.pro" }
+type subscribeConfig struct {
+	Symbol      string  `toml:"symbol"`
+	ClientId    string  `toml:"client_id"`
+	BucketSize  int64   `toml:"bucket_size"`
+	GracePeriod *int64  `toml:"grace_period"` // 0 表示不等待, 因此未配置时为 nil
+	Windows     []int64 `toml:"windows"`
+	Storage     string  `toml:"storage"`
+	Trades      *bool   `toml:"trades"`
+	Enabled     *bool   `toml:"enabled"`
+	Market      *Market `toml:"market"`
+}
+
+func (c *subscribeConfig) resolve(storage *Storage, market *Market) Subscribe {
+	if market == nil {
+		market = DefaultMarket()
+	}
+	sub := Subscribe{
+		Symbol:     c.Symbol,
+		ClientId:   c.ClientId,
+		BucketSize: c.BucketSize,
+		Windows:    c.Windows,
+		Storage:    &Storage{Driver: c.Storage, Trades: storage.Trades},
+		Market:     market.merge(c.Market),
+		Enabled:    c.Enabled == nil || *c.Enabled,
+	}
+	if sub.ClientId == "" {
+		sub.ClientId = sub.Symbol
+	}
+	if sub.BucketSize == 0 {
+		sub.BucketSize = DefaultBucketSize
+	}
+	sub.GracePeriod = DefaultGracePeriod
+	if c.GracePeriod != nil {
+		sub.GracePeriod = *c.GracePeriod
+	}
+	if len(sub.Windows) == 0 {
+		sub.Windows = SupportedWindows
+	}
+	if sub.Storage.Driver == "" {
+		sub.Storage.Driver = storage.Driver
+	}
+	if c.Trades != nil {
+		sub.Storage.Trades = *c.Trades
+	}
+	return sub
+}
+
+func (s *Subscribe) validate() error {
+	if !ValidSymbol(s.Symbol) {
+		return fmt.Errorf("invalid symbol %q, expected 2-32 lowercase letters or digits other than %q", s.Symbol, SharedPrefix)
+	}
+	if s.BucketSize <= 0 {
+		return fmt.Errorf("bucket_size must be positive, got %d", s.BucketSize)
+	}
+	if s.GracePeriod < 0 || s.GracePeriod >= s.BucketSize*1000 {
+		return fmt.Errorf("grace_period must be in [0, %d), got %d", s.BucketSize*1000, s.GracePeriod)
+	}
+	seen := make(map[int64]bool, len(s.Windows))
+	for _, window := range s.Windows {
+		if !supportedWindow(window) {
+			return fmt.Errorf("unsupported window %d, expected one of %v", window, SupportedWindows)
+		}
+		if seen[window] {
+			return fmt.Errorf("duplicate window %d", window)
+		}
+		seen[window] = true
+		if window%s.BucketSize != 0 {
+			return fmt.Errorf("window %d is not a multiple of bucket_size %d", window, s.BucketSize)
+		}
+	}
+	err := s.Market.validate()
+	if err != nil {
+		return err
+	}
+	return validDriver(s.Storage.Driver)
+}
+
+func supportedWindow(window int64) bool {
+	for _, w := range SupportedWindows {
+		if w == window {
+			return true
+		}
+	}
+	return false
+}
+
+// 加载所有订阅配置, 包含 [[subscriptions]] 及旧版 [server] subscribes, 未配置的项使用默认值,
+// storage 为全局存储配置, 行情连接配置默认使用 [market]. 配置有误时返回包含所有错误的 error
+func NewSubscribes(configs config.Configs, storage *Storage) ([]Subscribe, error) {
+	market, err := NewMarket("market", configs)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Subscriptions []*subscribeConfig `toml:"subscriptions"`
+	}
+	if configs["subscriptions"] != nil {
+		err = configs.Unmarshal(&file)
+		if err != nil {
+			return nil, fmt.Errorf("config.NewSubscribes: %w", err)
+		}
+	}
+	raw := file.Subscriptions
+	if configs["server"] != nil {
+		server := &Server{}
+		err := configs.UnmarshalSub("server", server)
+		if err != nil {
+			return nil, err
+		}
+		legacy, err := server.parseSubscribes()
+		if err != nil {
+			return nil, fmt.Errorf("config.NewSubscribes: %w", err)
+		}
+		raw = append(raw, legacy...)
+	}
+
+	var errs []string
+	var subs []Subscribe
+	symbols := make(map[string]bool, len(raw))
+	for i, c := range raw {
+		sub := c.resolve(storage, market)
+		err := sub.validate()
+		if err == nil && symbols[sub.Symbol] {
+			err = errors.New("duplicate symbol")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("subscriptions[%d] %s: %s", i, c.Symbol, err))
+			continue
+		}
+		symbols[sub.Symbol] = true
+		subs = append(subs, sub)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("config.NewSubscribes: invalid subscriptions:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return subs, nil
+}
+
+// 获取交易对的订阅配置, 配置文件中不存在时使用默认配置
+func FindSubscribe(configs config.Configs, storage *Storage, symbol string) (Subscribe, error) {
+	subs, err := NewSubscribes(configs, storage)
+	if err != nil {
+		return Subscribe{}, err
+	}
+	for _, sub := range subs {
+		if sub.Symbol == symbol {
+			return sub, nil
+		}
+	}
+	market, err := NewMarket("market", configs)
+	if err != nil {
+		return Subscribe{}, err
+	}
+	return NewSubscribe(symbol, "", storage, market), nil
 }
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/morgine/pkg/config"
+)
+
+func TestSubscribeGracePeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		toml    string
+		want    int64
+		wantErr string
+	}{
+		{name: "default", toml: ``, want: DefaultGracePeriod},
+		{name: "zero", toml: `grace_period = 0`, want: 0},
+		{name: "below bucket size", toml: `grace_period = 9999`, want: 9999},
+		{name: "equal to bucket size", toml: `grace_period = 10000`, wantErr: "grace_period must be in [0, 10000)"},
+		{name: "above bucket size", toml: `grace_period = 20000`, wantErr: "grace_period must be in [0, 10000)"},
+		{name: "negative", toml: `grace_period = -1`, wantErr: "grace_period must be in [0, 10000)"},
+		{name: "smaller bucket size", toml: "bucket_size = 5\nwindows = [10]\ngrace_period = 5000", wantErr: "grace_period must be in [0, 5000)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configs, err := config.UnmarshalMemory([]byte("[[subscriptions]]\nsymbol = \"btcusdt\"\n" + test.toml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			subs, err := NewSubscribes(configs, &Storage{Driver: "memory"})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("NewSubscribes() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subs[0].GracePeriod != test.want {
+				t.Fatalf("GracePeriod = %d, want %d", subs[0].GracePeriod, test.want)
+			}
+		})
+	}
+}
+
+func TestNewSubscribes(t *testing.T) {
+	tests := []struct {
+		name    string
+		toml    string
+		want    []string // 交易对及 client id, 格式为 symbol:clientId
+		wantErr string
+	}{
+		{
+			name: "legacy items without client id",
+			toml: "[server]\nsubscribes = \"btcusdt, ethusdt:1602\"",
+			want: []string{"btcusdt:btcusdt", "ethusdt:1602"},
+		},
+		{
+			name:    "legacy invalid item",
+			toml:    "[server]\nsubscribes = \"btcusdt:1:2\"",
+			wantErr: `invalid item "btcusdt:1:2"`,
+		},
+		{
+			name: "client id generated from symbol",
+			toml: "[[subscriptions]]\nsymbol = \"btcusdt\"\n[[subscriptions]]\nsymbol = \"ethusdt\"\nclient_id = \"1602\"",
+			want: []string{"btcusdt:btcusdt", "ethusdt:1602"},
+		},
+		{
+			name: "subscriptions and legacy items",
+			toml: "[server]\nsubscribes = \"ethusdt\"\n[[subscriptions]]\nsymbol = \"btcusdt\"",
+			want: []string{"btcusdt:btcusdt", "ethusdt:ethusdt"},
+		},
+		{
+			name:    "unsupported window",
+			toml:    "[[subscriptions]]\nsymbol = \"btcusdt\"\nwindows = [10, 20]",
+			wantErr: "unsupported window 20",
+		},
+		{
+			name:    "window not a multiple of bucket size",
+			toml:    "[[subscriptions]]\nsymbol = \"btcusdt\"\nbucket_size = 20\nwindows = [30]",
+			wantErr: "window 30 is not a multiple of bucket_size 20",
+		},
+		{
+			name:    "duplicate window",
+			toml:    "[[subscriptions]]\nsymbol = \"btcusdt\"\nwindows = [10, 30, 10]",
+			wantErr: "duplicate window 10",
+		},
+		{
+			name:    "duplicate symbol",
+			toml:    "[server]\nsubscribes = \"btcusdt\"\n[[subscriptions]]\nsymbol = \"btcusdt\"",
+			wantErr: "subscriptions[1] btcusdt: duplicate symbol",
+		},
+		{
+			name:    "reserved symbol",
+			toml:    "[[subscriptions]]\nsymbol = \"shared\"",
+			wantErr: `invalid symbol "shared"`,
+		},
+		{
+			name:    "all errors reported",
+			toml:    "[[subscriptions]]\nsymbol = \"BTC\"\n[[subscriptions]]\nsymbol = \"ethusdt\"\nwindows = [20]",
+			wantErr: "subscriptions[0] BTC: invalid symbol \"BTC\", expected 2-32 lowercase letters or digits other than \"shared\"\n\tsubscriptions[1] ethusdt: unsupported window 20",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configs, err := config.UnmarshalMemory([]byte(test.toml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			subs, err := NewSubscribes(configs, &Storage{Driver: "memory"})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("NewSubscribes() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, sub := range subs {
+				got = append(got, sub.Symbol+":"+sub.ClientId)
+			}
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Fatalf("subscribes = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"github.com/morgine/pkg/config"
+)
+
+// 存储类型
+const (
+	StoragePostgres = "postgres"
+	StorageSqlite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+// 存储配置
+type Storage struct {
+	Driver string `toml:"driver"` // 存储类型: postgres, sqlite, memory
+	Trades bool   `toml:"trades"` // 是否保存逐笔成交
+}
+
+// 加载存储配置, 未配置时默认使用 postgres
+func NewStorage(namespace string, configs config.Configs) (*Storage, error) {
+	cfg := &Storage{}
+	if configs[namespace] != nil {
+		err := configs.UnmarshalSub(namespace, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = StoragePostgres
+	}
+	err := validDriver(cfg.Driver)
+	if err != nil {
+		return nil, fmt.Errorf("config.NewStorage: %w", err)
+	}
+	return cfg, nil
+}
+
+func validDriver(driver string) error {
+	switch driver {
+	case StoragePostgres, StorageSqlite, StorageMemory:
+		return nil
+	default:
+		return fmt.Errorf("unsupported storage driver %q", driver)
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"github.com/morgine/pkg/config"
+	"path/filepath"
+	"time"
+)
+
+// 异步写入配置
+type Writer struct {
+	QueueSize     int    `toml:"queue_size"`     // 队列容量, 队列已满时新数据将被丢弃
+	BatchSize     int    `toml:"batch_size"`     // 单次批量写入的最大数据量
+	FlushInterval int    `toml:"flush_interval"` // 定时写入间隔(单位: 毫秒)
+	SpoolDir      string `toml:"spool_dir"`      // 数据库写入失败时暂存数据的目录
+}
+
+// 加载异步写入配置, 未配置的项使用默认值
+func NewWriter(namespace string, configs config.Configs) (*Writer, error) {
+	cfg := &Writer{}
+	if configs[namespace] != nil {
+		err := configs.UnmarshalSub(namespace, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 1000
+	}
+	if cfg.SpoolDir == "" {
+		cfg.SpoolDir = "spool"
+	}
+	return cfg, nil
+}
+
+func (w *Writer) Interval() time.Duration {
+	return time.Duration(w.FlushInterval) * time.Millisecond
+}
+
+// 数据暂存文件路径
+func (w *Writer) SpoolFile(symbol, table string) string {
+	return filepath.Join(w.SpoolDir, symbol+"_"+table+".jsonl")
+}
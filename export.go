@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/morgine/pkg/config"
+	config2 "huobi/config"
+	"huobi/export"
+	"huobi/model"
+	"huobi/routes"
+	"io"
+	"os"
+)
+
+// 导出数据:
+//
+//	export -symbol xrpusdt [-table sections|trades] [-format csv|parquet] [-start 秒] [-end 秒] [-o 文件]
+func runExport(configs config.Configs, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	symbol := fs.String("symbol", "", "交易对")
+	table := fs.String("table", export.TableSections, "数据表: sections, trades")
+	format := fs.String("format", export.FormatCSV, "导出格式: csv, parquet")
+	start := fs.Int64("start", 0, "开始时间(单位: 秒)")
+	end := fs.Int64("end", 0, "结束时间(单位: 秒), 为 0 时不限制结束时间")
+	output := fs.String("o", "", "输出文件, 默认输出到标准输出")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *symbol == "" {
+		return errors.New("export: -symbol is required")
+	}
+	options := &export.Options{Table: *table, Format: *format, Start: *start, End: *end}
+	err = options.Validate()
+	if err != nil {
+		return err
+	}
+	storageConfig, err := config2.NewStorage("storage", configs)
+	if err != nil {
+		return err
+	}
+	subscribe, err := config2.FindSubscribe(configs, storageConfig, *symbol)
+	if err != nil {
+		return err
+	}
+	storage, err := routes.NewStorage(subscribe.Storage, *symbol, configs)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+	if schema, ok := storage.(model.SchemaStorage); ok {
+		err = schema.CheckSchema()
+		if err != nil {
+			return err
+		}
+	}
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return export.Export(w, storage, options)
+}
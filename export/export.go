@@ -0,0 +1,258 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/xitongsys/parquet-go/writer"
+	"huobi/model"
+	"io"
+	"strconv"
+)
+
+// 导出格式
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// 导出数据表
+const (
+	TableSections = "sections"
+	TableTrades   = "trades"
+)
+
+// parquet 行组大小, 行组写满后才会输出, 决定了导出时占用的内存
+const parquetRowGroupSize = 8 * 1024 * 1024
+
+type Options struct {
+	Table  string // 数据表: sections, trades
+	Format string // 导出格式: csv, parquet
+	Start  int64  // 开始时间(单位: 秒)
+	End    int64  // 结束时间(单位: 秒), 为 0 时不限制结束时间
+}
+
+func (o *Options) Validate() error {
+	if o.Table != TableSections && o.Table != TableTrades {
+		return fmt.Errorf("unsupported table %q", o.Table)
+	}
+	if o.Format != FormatCSV && o.Format != FormatParquet {
+		return fmt.Errorf("unsupported format %q", o.Format)
+	}
+	if o.Start < 0 || o.End < 0 || (o.End > 0 && o.End <= o.Start) {
+		return fmt.Errorf("invalid time range [%d, %d)", o.Start, o.End)
+	}
+	return nil
+}
+
+// 导出文件名
+func (o *Options) Filename(symbol string) string {
+	return fmt.Sprintf("%s_%s.%s", symbol, o.Table, o.Format)
+}
+
+// 逐行读取数据并写入 w, 不会一次性加载全部数据
+func Export(w io.Writer, storage model.Storage, options *Options) error {
+	err := options.Validate()
+	if err != nil {
+		return err
+	}
+	var enc encoder
+	if options.Format == FormatCSV {
+		enc = newCSVEncoder(w, options.Table)
+	} else {
+		enc, err = newParquetEncoder(w, options.Table)
+		if err != nil {
+			return err
+		}
+	}
+	if options.Table == TableSections {
+		err = storage.EachSection(options.Start, options.End, func(s *model.Section) error {
+			return enc.encode(s)
+		})
+	} else {
+		err = storage.EachTrade(options.Start*1000, options.End*1000, func(t *model.Trade) error {
+			return enc.encode(t)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return enc.close()
+}
+
+type encoder interface {
+	encode(row interface{}) error
+	close() error
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header []string
+	rows   int
+}
+
+func newCSVEncoder(w io.Writer, table string) *csvEncoder {
+	e := &csvEncoder{w: csv.NewWriter(w)}
+	if table == TableSections {
+		e.header = model.SectionColumns
+	} else {
+		e.header = tradeHeader
+	}
+	return e
+}
+
+var tradeHeader = []string{"trade_id", "timestamp", "price", "amount", "direction"}
+
+func (e *csvEncoder) encode(row interface{}) error {
+	if e.rows == 0 {
+		err := e.w.Write(e.header)
+		if err != nil {
+			return err
+		}
+	}
+	e.rows++
+	var record []string
+	switch r := row.(type) {
+	case *model.Section:
+		for _, column := range model.SectionColumns {
+			v, _ := r.Column(column)
+			record = append(record, fmt.Sprint(v))
+		}
+	case *model.Trade:
+		record = []string{
+			strconv.FormatInt(r.TradeId, 10),
+			strconv.FormatInt(r.Timestamp, 10),
+			r.Price.String(),
+			r.Amount.String(),
+			r.Direction,
+		}
+	}
+	err := e.w.Write(record)
+	if err != nil {
+		return err
+	}
+	// 定期输出缓冲数据, 使下载端尽快收到数据
+	if e.rows%1000 == 0 {
+		e.w.Flush()
+		return e.w.Error()
+	}
+	return nil
+}
+
+func (e *csvEncoder) close() error {
+	if e.rows == 0 {
+		err := e.w.Write(e.header)
+		if err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type parquetSection struct {
+	ID           int64 `parquet:"name=id, type=INT64"`
+	EndTime      int64 `parquet:"name=end_time, type=INT64"`
+	Buy10        int64 `parquet:"name=buy10, type=INT64"`
+	Sell10       int64 `parquet:"name=sell10, type=INT64"`
+	Inflow10     int64 `parquet:"name=inflow10, type=INT64"`
+	Buy30        int64 `parquet:"name=buy30, type=INT64"`
+	Sell30       int64 `parquet:"name=sell30, type=INT64"`
+	Inflow30     int64 `parquet:"name=inflow30, type=INT64"`
+	Buy60        int64 `parquet:"name=buy60, type=INT64"`
+	Sell60       int64 `parquet:"name=sell60, type=INT64"`
+	Inflow60     int64 `parquet:"name=inflow60, type=INT64"`
+	Buy300       int64 `parquet:"name=buy300, type=INT64"`
+	Sell300      int64 `parquet:"name=sell300, type=INT64"`
+	Inflow300    int64 `parquet:"name=inflow300, type=INT64"`
+	Buy900       int64 `parquet:"name=buy900, type=INT64"`
+	Sell900      int64 `parquet:"name=sell900, type=INT64"`
+	Inflow900    int64 `parquet:"name=inflow900, type=INT64"`
+	Buy3600      int64 `parquet:"name=buy3600, type=INT64"`
+	Sell3600     int64 `parquet:"name=sell3600, type=INT64"`
+	Inflow3600   int64 `parquet:"name=inflow3600, type=INT64"`
+	Buy14400     int64 `parquet:"name=buy14400, type=INT64"`
+	Sell14400    int64 `parquet:"name=sell14400, type=INT64"`
+	Inflow14400  int64 `parquet:"name=inflow14400, type=INT64"`
+	BucketBuy    int64 `parquet:"name=bucket_buy, type=INT64"`
+	BucketSell   int64 `parquet:"name=bucket_sell, type=INT64"`
+	BucketInflow int64 `parquet:"name=bucket_inflow, type=INT64"`
+	Partial      bool  `parquet:"name=partial, type=BOOLEAN"`
+	Backfilled   bool  `parquet:"name=backfilled, type=BOOLEAN"`
+}
+
+// 价格及数量以浮点数保存, 需要精确值时使用 csv 格式
+type parquetTrade struct {
+	TradeId   int64   `parquet:"name=trade_id, type=INT64"`
+	Timestamp int64   `parquet:"name=timestamp, type=INT64"`
+	Price     float64 `parquet:"name=price, type=DOUBLE"`
+	Amount    float64 `parquet:"name=amount, type=DOUBLE"`
+	Direction string  `parquet:"name=direction, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type parquetEncoder struct {
+	w *writer.ParquetWriter
+}
+
+func newParquetEncoder(w io.Writer, table string) (*parquetEncoder, error) {
+	var schema interface{} = new(parquetSection)
+	if table == TableTrades {
+		schema = new(parquetTrade)
+	}
+	pw, err := writer.NewParquetWriterFromWriter(w, schema, 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.RowGroupSize = parquetRowGroupSize
+	return &parquetEncoder{w: pw}, nil
+}
+
+func (e *parquetEncoder) encode(row interface{}) error {
+	switch r := row.(type) {
+	case *model.Section:
+		return e.w.Write(&parquetSection{
+			ID:           int64(r.ID),
+			EndTime:      r.EndTime,
+			Buy10:        r.Buy10,
+			Sell10:       r.Sell10,
+			Inflow10:     r.Inflow10,
+			Buy30:        r.Buy30,
+			Sell30:       r.Sell30,
+			Inflow30:     r.Inflow30,
+			Buy60:        r.Buy60,
+			Sell60:       r.Sell60,
+			Inflow60:     r.Inflow60,
+			Buy300:       r.Buy300,
+			Sell300:      r.Sell300,
+			Inflow300:    r.Inflow300,
+			Buy900:       r.Buy900,
+			Sell900:      r.Sell900,
+			Inflow900:    r.Inflow900,
+			Buy3600:      r.Buy3600,
+			Sell3600:     r.Sell3600,
+			Inflow3600:   r.Inflow3600,
+			Buy14400:     r.Buy14400,
+			Sell14400:    r.Sell14400,
+			Inflow14400:  r.Inflow14400,
+			BucketBuy:    r.BucketBuy,
+			BucketSell:   r.BucketSell,
+			BucketInflow: r.BucketInflow,
+			Partial:      r.Partial,
+			Backfilled:   r.Backfilled,
+		})
+	case *model.Trade:
+		price, _ := r.Price.Float64()
+		amount, _ := r.Amount.Float64()
+		return e.w.Write(&parquetTrade{
+			TradeId:   r.TradeId,
+			Timestamp: r.Timestamp,
+			Price:     price,
+			Amount:    amount,
+			Direction: r.Direction,
+		})
+	}
+	return nil
+}
+
+func (e *parquetEncoder) close() error {
+	return e.w.WriteStop()
+}
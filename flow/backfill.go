@@ -0,0 +1,188 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"github.com/shopspring/decimal"
+	"huobi/metrics"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 通过 REST 接口 market/history/trade 补全缺失成交的配置
+type Backfill struct {
+	URL     string        // REST 接口地址, 如 https://api.huobi.pro
+	Size    int           // 获取的最近成交数量, 最大 2000
+	Timeout time.Duration // 请求超时时间
+	TradeId bool          // 推送的成交 id 不连续时是否补全, 只适用于成交 id 连续递增的交易对
+}
+
+// 默认使用火币 REST 接口
+func DefaultBackfill() *Backfill {
+	return &Backfill{
+		URL:     "https://api.huobi.pro",
+		Size:    2000,
+		Timeout: 10 * time.Second,
+	}
+}
+
+// 设置补全配置, 为 nil 时不补全. 在 Subscribe 之前调用
+func (c *Client) SetBackfill(backfill *Backfill) {
+	c.backfill = backfill
+}
+
+// REST 接口返回的成交, 按成交时间分组
+type historyTradeResponse struct {
+	Status string `json:"status"`
+	ErrMsg string `json:"err-msg"`
+	Data   []struct {
+		Data []struct {
+			TradeId   int64           `json:"trade-id"`
+			Price     decimal.Decimal `json:"price"`
+			Amount    decimal.Decimal `json:"amount"`
+			Direction string          `json:"direction"`
+			Timestamp int64           `json:"ts"`
+		} `json:"data"`
+	} `json:"data"`
+}
+
+// 获取最近的成交, 按成交 id 升序排列
+func fetchTrades(backfill *Backfill, symbol string) ([]market.Trade, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("size", strconv.Itoa(backfill.Size))
+	client := &http.Client{Timeout: backfill.Timeout}
+	resp, err := client.Get(backfill.URL + "/market/history/trade?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("market/history/trade: unexpected status %s", resp.Status)
+	}
+	response := &historyTradeResponse{}
+	err = json.NewDecoder(resp.Body).Decode(response)
+	if err != nil {
+		return nil, fmt.Errorf("market/history/trade: %w", err)
+	}
+	if response.Status != "ok" {
+		return nil, fmt.Errorf("market/history/trade: %s", response.ErrMsg)
+	}
+	var trades []market.Trade
+	for _, group := range response.Data {
+		for _, t := range group.Data {
+			trades = append(trades, market.Trade{
+				TradeId:   t.TradeId,
+				Price:     t.Price,
+				Amount:    t.Amount,
+				Direction: t.Direction,
+				Timestamp: t.Timestamp,
+			})
+		}
+	}
+	sort.Slice(trades, func(i, j int) bool {
+		return trades[i].TradeId < trades[j].TradeId
+	})
+	return trades, nil
+}
+
+// 推送的成交 id 与最近处理的成交 id 不连续时返回推送的最小成交 id, 否则返回 0.
+// 假设同一交易对的成交 id 连续递增, 未开启 Backfill.TradeId 时不检测. 同一数据流时长内只检测一次, 避免频繁请求
+func (c *Client) tradeIdGap(trades []market.Trade) (first int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last := c.lastTrade.TradeId
+	if !c.backfill.TradeId || last == 0 || time.Since(c.backfilledAt) < time.Duration(c.flowDuration)*time.Second {
+		return 0
+	}
+	for _, t := range trades {
+		if t.TradeId > last && (first == 0 || t.TradeId < first) {
+			first = t.TradeId
+		}
+	}
+	if first > last+1 {
+		return first
+	}
+	return 0
+}
+
+// 补全最近处理的成交之后、成交 id 小于 until 的成交, until 为 0 时不限制. 补全的成交与推送的成交一样处理.
+// 没有处理过成交时(如使用保存的数据流恢复后)补全最近结束的数据流之后的成交.
+// 返回是否补全了全部缺失的成交, 获取的成交未能覆盖最近处理的成交时, 缺失时长之后的数据区间标记为不完整
+func (c *Client) fill(reason string, until int64) (covered bool) {
+	c.mu.Lock()
+	last, since := c.lastTrade.TradeId, int64(0)
+	if last == 0 && c.closed > 0 {
+		since = (c.closed + c.flowDuration) * 1000
+	}
+	c.backfilledAt = time.Now()
+	c.mu.Unlock()
+	if last == 0 && since == 0 {
+		return false
+	}
+	metrics.TradeGaps.WithLabelValues(c.symbol, reason).Inc()
+	trades, err := fetchTrades(c.backfill, c.symbol)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		applogger.Warn("symbol %s backfill after %s failed: %s", c.symbol, reason, err)
+		c.markGap(0)
+		return false
+	}
+	var missing []market.Trade
+	for _, t := range trades {
+		if until > 0 && t.TradeId >= until {
+			continue
+		}
+		if last > 0 && t.TradeId > last || last == 0 && t.Timestamp >= since {
+			missing = append(missing, t)
+		}
+	}
+	// 获取的最早成交不晚于最近处理的成交时, 之后的成交都已获取
+	covered = len(trades) > 0 && (last > 0 && trades[0].TradeId <= last || last == 0 && trades[0].Timestamp < since)
+	if !covered {
+		var gapAt int64
+		if len(trades) > 0 {
+			gapAt = trades[0].Timestamp / 1000
+		}
+		c.markGap(gapAt - gapAt%c.flowDuration)
+	}
+	continuous := c.continuous
+	c.continuous = continuous || covered
+	c.backfilling = true
+	n := c.process(missing, true)
+	c.backfilling = false
+	c.continuous = continuous
+	metrics.BackfilledTrades.WithLabelValues(c.symbol).Add(float64(n))
+	if !covered {
+		applogger.Warn("symbol %s backfilled %d trades after %s, earlier trades are incomplete", c.symbol, n, reason)
+		return false
+	}
+	applogger.Info("symbol %s backfilled %d trades after %s", c.symbol, n, reason)
+	return true
+}
+
+// 未结束的数据流及开始时间不晚于 gapAt(单位: 秒)的数据流可能缺少成交, 数据区间从这些数据流之后重新累计完整时长,
+// 在此之前 Partial 为 true
+func (c *Client) markGap(gapAt int64) {
+	if len(c.open) > 0 && c.open[len(c.open)-1].Timestamp > gapAt {
+		gapAt = c.open[len(c.open)-1].Timestamp
+	}
+	if gapAt > c.gapAt {
+		c.gapAt = gapAt
+	}
+	c.resetStart()
+}
+
+// 数据区间从下一个数据流开始累计
+func (c *Client) resetStart() {
+	for _, container := range c.containers {
+		container.start = 0
+	}
+}
@@ -0,0 +1,241 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+)
+
+// 模拟 REST 接口 market/history/trade, 与火币一样按成交时间倒序分组返回
+func newHistoryServer(t *testing.T, trades []market.Trade) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/market/history/trade" || r.URL.Query().Get("symbol") != "testusdt" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		type trade struct {
+			TradeId   int64  `json:"trade-id"`
+			Price     string `json:"price"`
+			Amount    string `json:"amount"`
+			Direction string `json:"direction"`
+			Timestamp int64  `json:"ts"`
+		}
+		type group struct {
+			Timestamp int64   `json:"ts"`
+			Data      []trade `json:"data"`
+		}
+		var groups []group
+		for i := len(trades) - 1; i >= 0; i-- {
+			t := trades[i]
+			row := trade{t.TradeId, t.Price.String(), t.Amount.String(), t.Direction, t.Timestamp}
+			if len(groups) > 0 && groups[len(groups)-1].Timestamp == t.Timestamp {
+				groups[len(groups)-1].Data = append(groups[len(groups)-1].Data, row)
+			} else {
+				groups = append(groups, group{Timestamp: t.Timestamp, Data: []trade{row}})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "data": groups})
+	}))
+}
+
+func (tc *testClient) setBackfill(server *httptest.Server) {
+	tc.SetBackfill(&Backfill{URL: server.URL, Size: 2000, Timeout: time.Second})
+}
+
+func TestBackfillAfterWarmFlows(t *testing.T) {
+	tests := []struct {
+		name        string
+		history     []market.Trade
+		want        []int64
+		wantBuy     []int64
+		wantPartial bool
+	}{
+		{
+			name: "covered",
+			// 195000 属于已恢复的数据流, 不再计入
+			history: []market.Trade{buy(1, 195000), buy(2, 205000), buy(3, 226000), buy(4, 226000), buy(5, 245000)},
+			want:    []int64{200, 210, 220, 230},
+			wantBuy: []int64{10, 0, 20, 0},
+		},
+		{
+			name:        "not covered",
+			history:     []market.Trade{buy(3, 226000), buy(4, 226000), buy(5, 245000)},
+			want:        []int64{220},
+			wantBuy:     []int64{20},
+			wantPartial: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newHistoryServer(t, test.history)
+			defer server.Close()
+			tc := newTestClient(10, []int64{30}, time.Second)
+			tc.setBackfill(server)
+			tc.WarmFlows(warmFlows(100, 190, 10))
+			covered := tc.fill("connect", 0)
+			if covered == test.wantPartial {
+				t.Fatalf("fill() = %v, want %v", covered, !test.wantPartial)
+			}
+			if got := tc.timestamps(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("closed flows = %v, want %v", got, test.want)
+			}
+			for i, flow := range tc.closed {
+				if flow.Buy != test.wantBuy[i] || !flow.Backfilled && flow.Buy > 0 {
+					t.Fatalf("flow %+v, want Buy %d and Backfilled", flow, test.wantBuy[i])
+				}
+			}
+			if got := tc.GetSection(30).Partial; got != test.wantPartial {
+				t.Fatalf("Partial = %v, want %v", got, test.wantPartial)
+			}
+		})
+	}
+}
+
+func TestFetchTrades(t *testing.T) {
+	server := newHistoryServer(t, []market.Trade{buy(1, 100000), sell(2, 100000), buy(3, 101000), buy(4, 103000)})
+	defer server.Close()
+	trades, err := fetchTrades(&Backfill{URL: server.URL, Size: 10, Timeout: time.Second}, "testusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, trade := range trades {
+		ids = append(ids, trade.TradeId)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3, 4}) {
+		t.Fatalf("trade ids = %v, want sorted [1 2 3 4]", ids)
+	}
+	if trades[1].Direction != "sell" || trades[1].Timestamp != 100000 || !trades[1].Amount.Equal(buy(0, 0).Amount) {
+		t.Fatalf("trade = %+v, fields not mapped", trades[1])
+	}
+}
+
+func TestFetchTradesError(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"status code", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}},
+		{"error status", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"status":"error","err-msg":"invalid symbol"}`))
+		}},
+		{"malformed body", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"status":`))
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(test.handler)
+			defer server.Close()
+			_, err := fetchTrades(&Backfill{URL: server.URL, Size: 10, Timeout: time.Second}, "testusdt")
+			if err == nil {
+				t.Fatalf("fetchTrades() should fail")
+			}
+		})
+	}
+}
+
+func TestBackfillAfterTrades(t *testing.T) {
+	tests := []struct {
+		name        string
+		history     []market.Trade
+		fail        bool
+		wantCovered bool
+		wantBuy     int64 // 100 至 120 的数据流成交额
+	}{
+		{
+			name:        "covered",
+			history:     []market.Trade{buy(1, 100500), buy(2, 101000), buy(3, 112000), buy(4, 113000), buy(5, 125000)},
+			wantCovered: true,
+			wantBuy:     50,
+		},
+		{
+			name:    "does not reach last trade",
+			history: []market.Trade{buy(4, 113000), buy(5, 125000)},
+			wantBuy: 40,
+		},
+		{
+			name:    "fetch error",
+			fail:    true,
+			wantBuy: 40,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newHistoryServer(t, test.history)
+			if test.fail {
+				server.Close()
+			}
+			defer server.Close()
+			tc := newTestClient(10, []int64{20}, time.Second)
+			tc.setBackfill(server)
+			tc.connect(100)
+			tc.Push([]market.Trade{buy(1, 100500), buy(2, 101000)})
+			tc.onDisconnected()
+			if covered := tc.fill("connect", 0); covered != test.wantCovered {
+				t.Fatalf("fill() = %v, want %v", covered, test.wantCovered)
+			}
+			// 重连后重发已补全的成交, 补全失败时这些成交为新的成交
+			tc.connect(130)
+			tc.Push([]market.Trade{buy(4, 113000), buy(5, 125000), buy(6, 131000)})
+			tc.flush(131000)
+			var total int64
+			for _, flow := range tc.closed {
+				total += flow.Buy
+			}
+			if total != test.wantBuy {
+				t.Fatalf("total Buy = %d, want %d", total, test.wantBuy)
+			}
+			if got := tc.GetSection(20).Partial; got == test.wantCovered {
+				t.Fatalf("Partial = %v, want %v", got, !test.wantCovered)
+			}
+		})
+	}
+}
+
+func TestTradeIdGap(t *testing.T) {
+	tc := newTestClient(10, []int64{30}, time.Second)
+	tc.SetBackfill(&Backfill{})
+	tc.Push([]market.Trade{buy(1, 100500), buy(2, 101000)})
+	if first := tc.tradeIdGap([]market.Trade{buy(5, 102000), buy(4, 102000)}); first != 0 {
+		t.Fatalf("tradeIdGap() = %d, want 0 when disabled", first)
+	}
+	tc.backfill.TradeId = true
+	tests := []struct {
+		trades []market.Trade
+		want   int64
+	}{
+		{[]market.Trade{buy(3, 102000)}, 0},
+		{[]market.Trade{buy(2, 101000), buy(3, 102000)}, 0},
+		{[]market.Trade{buy(5, 102000), buy(4, 102000)}, 4},
+	}
+	for _, test := range tests {
+		if first := tc.tradeIdGap(test.trades); first != test.want {
+			t.Fatalf("tradeIdGap(%v) = %d, want %d", test.trades, first, test.want)
+		}
+	}
+	// 同一数据流时长内只检测一次
+	tc.backfilledAt = time.Now()
+	if first := tc.tradeIdGap([]market.Trade{buy(5, 102000)}); first != 0 {
+		t.Fatalf("tradeIdGap() = %d, want 0 within flow duration of last backfill", first)
+	}
+}
+
+func TestReconnectWithoutBackfill(t *testing.T) {
+	tc := newTestClient(10, []int64{30}, time.Second)
+	tc.WarmFlows(warmFlows(100, 190, 10))
+	tc.onConnected()
+	tc.onDisconnected()
+	tc.onConnected()
+	for _, container := range tc.containers {
+		if container.start != 0 {
+			t.Fatalf("reconnect without backfill should mark a gap")
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package flow
 
 import (
-	"github.com/huobirdcenter/huobi_golang/config"
 	"github.com/huobirdcenter/huobi_golang/logging/applogger"
-	"github.com/huobirdcenter/huobi_golang/pkg/client/marketwebsocketclient"
 	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
 	"github.com/shopspring/decimal"
+	"huobi/metrics"
 	"sync"
+	"time"
 )
 
 type Client struct {
@@ -14,9 +14,119 @@ type Client struct {
 	symbol       string
 	containers   []*container
 	handler      Handler
+	tradeHandler TradeHandler
 	flowDuration int64
-	flow         *Flow
+	endpoint     *Endpoint
+	grace        time.Duration   // 数据流结束后等待迟到成交的时间
+	open         []*Flow         // 未结束的数据流, 按开始时间排序
+	closing      *Flow           // 正在结束的数据流, 只在调用 Handler 时不为 nil
+	price        decimal.Decimal // 最近一笔成交价格
+	closed       int64           // 最近结束的数据流开始时间
+	fillFrom     int64           // 大于 0 时为不早于该时间(单位: 秒)且没有成交的数据流时长生成空数据流, 连接成功后设置, 断开后清零
+	continuous   bool            // 处理的成交是连续的(如保存的成交及补全的成交), 为最近结束的数据流之后没有成交的时长生成空数据流
+	gapAt        int64           // 开始时间不晚于该时间(单位: 秒)的数据流可能缺少成交
+	dedupe       *Deduper        // 去除重连后重发的成交
+	backfill     *Backfill       // 为 nil 时不补全缺失的成交
+	backfilling  bool            // 正在处理补全的成交
+	backfilledAt time.Time       // 最近一次补全的时间
+	lastTrade    market.Trade    // 成交 id 最大的已处理成交
+	online       bool            // websocket 已连接且已补全缺失的成交, 由 statusMu 保护
 	mu           sync.Mutex
+	status       Status
+	statusMu     sync.Mutex
+}
+
+// 订阅状态, 时间单位为秒
+type Status struct {
+	Connected     bool  `json:"connected"`       // websocket 是否连接
+	ConnectedAt   int64 `json:"connected_at"`    // 最近一次连接成功的时间
+	LastMessageAt int64 `json:"last_message_at"` // 最近一次收到推送的时间
+	LastPingAt    int64 `json:"last_ping_at"`    // 最近一次收到服务端 ping 的时间
+	LastTradeAt   int64 `json:"last_trade_at"`   // 最近一笔成交的时间
+	Reconnects    int64 `json:"reconnects"`      // 重连次数
+	Duplicates    int64 `json:"duplicates"`      // 丢弃的重复成交数量
+}
+
+// 获取订阅状态. 连接成功并补全缺失的成交后, 在 PingTimeout 内收到过推送或 ping 视为已连接,
+// 超过该时间时 websocket 客户端会断开重连. 没有成交的交易对只会收到 ping
+func (c *Client) Status() Status {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	s := c.status
+	last := s.ConnectedAt
+	if s.LastMessageAt > last {
+		last = s.LastMessageAt
+	}
+	if s.LastPingAt > last {
+		last = s.LastPingAt
+	}
+	s.Connected = c.online && s.ConnectedAt > 0 && time.Since(time.Unix(last, 0)) < c.endpoint.PingTimeout
+	return s
+}
+
+func (c *Client) onConnected() {
+	c.statusMu.Lock()
+	if c.status.ConnectedAt > 0 {
+		c.status.Reconnects++
+	}
+	c.status.ConnectedAt = time.Now().Unix()
+	c.statusMu.Unlock()
+	// 在发送订阅请求之前补全断线期间缺失的成交
+	covered := c.backfill != nil && c.fill("connect", 0)
+	// 只为连接成功后完整的数据流时长生成空数据流, 补全了断线期间的全部成交时断线期间的时长同样生成.
+	// 未补全时断线期间的成交已缺失, 之后的数据区间标记为不完整
+	c.mu.Lock()
+	c.fillFrom = (time.Now().Unix() + c.flowDuration - 1) / c.flowDuration * c.flowDuration
+	if covered && c.closed > 0 {
+		c.fillFrom = c.closed + c.flowDuration
+	}
+	if !covered {
+		c.markGap(c.fillFrom - c.flowDuration)
+	}
+	c.mu.Unlock()
+	c.setOnline(true)
+}
+
+func (c *Client) onDisconnected() {
+	c.setOnline(false)
+	c.mu.Lock()
+	c.fillFrom = 0
+	c.mu.Unlock()
+}
+
+func (c *Client) setOnline(online bool) {
+	c.statusMu.Lock()
+	c.online = online
+	c.statusMu.Unlock()
+}
+
+func (c *Client) onPing() {
+	c.statusMu.Lock()
+	c.status.LastPingAt = time.Now().Unix()
+	c.statusMu.Unlock()
+}
+
+func (c *Client) onMessage(trades []market.Trade) {
+	c.statusMu.Lock()
+	c.status.LastMessageAt = time.Now().Unix()
+	if len(trades) > 0 {
+		c.status.LastTradeAt = trades[len(trades)-1].Timestamp / 1000
+	}
+	c.statusMu.Unlock()
+}
+
+// 当前监听的数据区间时长, 与 GetSection 一样只应在 Handler 中调用
+func (c *Client) Durations() []int64 {
+	durations := make([]int64, len(c.containers))
+	for i, container := range c.containers {
+		durations[i] = container.duration
+	}
+	return durations
+}
+
+// 刚结束的数据流, 与 GetSection 一样只应在 Handler 中调用
+func (c *Client) Flow() *Flow {
+	return c.closing
 }
 
 func (c *Client) GetSection(duration int64) *Section {
@@ -28,23 +138,59 @@ func (c *Client) GetSection(duration int64) *Section {
 	return nil
 }
 
+// 数据快照
+type Snapshot struct {
+	Durations []int64   // 数据区间时长, 与 Sections 一一对应
+	Sections  []Section // 各时长当前的数据区间
+	Flow      Flow      // 正在累计的数据流, 有多个时为最新的数据流
+	Flows     []Flow    // 所有正在累计的数据流, 宽限时间内可能有多个, 按时间递增排列
+}
+
+// 获取当前数据快照, 与推送数据互斥, 返回值为副本
+func (c *Client) Snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &Snapshot{
+		Durations: make([]int64, len(c.containers)),
+		Sections:  make([]Section, len(c.containers)),
+	}
+	if len(c.open) > 0 {
+		s.Flow = *c.open[len(c.open)-1]
+	}
+	s.Flows = make([]Flow, len(c.open))
+	for i, f := range c.open {
+		s.Flows[i] = *f
+	}
+	for i, container := range c.containers {
+		s.Durations[i] = container.duration
+		s.Sections[i] = *container.section
+	}
+	return s
+}
+
 func NewClient(clientId, symbol string, flowDuration int64) *Client {
 	return &Client{
 		clientId:     clientId,
 		symbol:       symbol,
 		containers:   nil,
 		flowDuration: flowDuration,
-		flow:         &Flow{},
+		endpoint:     DefaultEndpoint(),
+		dedupe:       NewDeduper(DefaultDedupeSize),
 		mu:           sync.Mutex{},
 	}
 }
 
 type SectionGetter interface {
+	Durations() []int64
+	Flow() *Flow
 	GetSection(duration int64) *Section
 }
 
 type Handler func(price decimal.Decimal, sectionGetter SectionGetter)
 
+// 逐笔成交处理器, 成交时间单位为毫秒, 在订阅协程中调用, 不可阻塞
+type TradeHandler func(trades []market.Trade)
+
 func (c *Client) Listen(durations []int64, handler Handler) {
 	for _, duration := range durations {
 		c.containers = append(c.containers, newContainer(duration))
@@ -52,62 +198,328 @@ func (c *Client) Listen(durations []int64, handler Handler) {
 	c.handler = handler
 }
 
+// 修改监听的数据区间时长, 已存在的时长保留累计的数据, 新增的时长从空数据区间开始累计
+func (c *Client) SetDurations(durations []int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	containers := make([]*container, 0, len(durations))
+	for _, duration := range durations {
+		var found *container
+		for _, old := range c.containers {
+			if old.duration == duration {
+				found = old
+				break
+			}
+		}
+		if found == nil {
+			found = newContainer(duration)
+		}
+		containers = append(containers, found)
+	}
+	c.containers = containers
+}
+
+// 设置行情 websocket 连接配置, 在 Subscribe 之前调用
+func (c *Client) SetEndpoint(endpoint *Endpoint) {
+	c.endpoint = endpoint
+}
+
+// 设置数据流结束后等待迟到成交的时间, 超过该时间到达的成交计入最早未结束的数据流
+func (c *Client) SetGracePeriod(grace time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.grace = grace
+}
+
+func (c *Client) gracePeriod() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.grace
+}
+
+// 监听逐笔成交
+func (c *Client) ListenTrades(handler TradeHandler) {
+	c.tradeHandler = handler
+}
+
+// 设置推送的成交是否连续, 连续时(如导入的历史成交)为没有成交的数据流时长生成空数据流. 在 Push 之前调用
+func (c *Client) SetContinuous(continuous bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.continuous = continuous
+}
+
+// 按时间顺序结束所有未结束的数据流, 不等待迟到成交, 用于导入历史成交结束时
+func (c *Client) CloseFlows() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.open) > 0 {
+		last := c.open[len(c.open)-1].Timestamp
+		c.closeUntil((last+c.flowDuration)*1000+c.grace.Milliseconds(), true)
+	}
+}
+
 func (c *Client) Subscribe() (closeFunc func()) {
-	return Subscribe(c.symbol, c.clientId, func(response market.SubscribeTradeResponse) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go c.tick(stop, done)
+	unsubscribe := subscribe(c.endpoint, c.symbol, c.clientId, c.onConnected, c.onDisconnected, c.onPing, func(response market.SubscribeTradeResponse) {
 		if response.Tick != nil && response.Tick.Data != nil {
-			c.mu.Lock()
-			defer c.mu.Unlock()
-			for idx, t := range response.Tick.Data {
-				t.Timestamp = t.Timestamp / 1000
-				if c.flow.Timestamp == 0 {
-					c.flow.Timestamp = t.Timestamp
-				}
-				cash := t.Price.Mul(t.Amount).IntPart()
-				if t.Direction == "buy" {
-					c.flow.Buy += cash
-					c.flow.Inflow += cash
-				} else {
-					c.flow.Sell += cash
-					c.flow.Inflow -= cash
-				}
-				if idx == len(response.Tick.Data)-1 {
-					if t.Timestamp >= c.flow.Timestamp+c.flowDuration {
-						for _, container := range c.containers {
-							container.push(c.flow)
-						}
-						c.handler(t.Price, c)
-						c.flow = &Flow{}
-					}
+			trades := response.Tick.Data
+			c.onMessage(trades)
+			start := time.Now()
+			if c.backfill != nil {
+				if first := c.tradeIdGap(trades); first > 0 {
+					c.fill("trade_id", first)
 				}
 			}
+			c.Push(trades)
+			metrics.MessageProcessing.WithLabelValues(c.symbol).Observe(time.Since(start).Seconds())
+			metrics.TradesReceived.WithLabelValues(c.symbol).Add(float64(len(trades)))
+			if len(trades) > 0 {
+				metrics.ObserveTrade(c.symbol, trades[len(trades)-1].Timestamp)
+			}
 		}
 	})
+	return func() {
+		close(stop)
+		<-done
+		unsubscribe()
+		c.statusMu.Lock()
+		c.status.ConnectedAt = 0
+		c.statusMu.Unlock()
+		c.mu.Lock()
+		c.fillFrom = 0
+		c.mu.Unlock()
+	}
+}
+
+// 处理一批成交数据, 成交时间单位为毫秒. 订阅时由推送数据调用, 也可用于导入历史成交
+func (c *Client) Push(trades []market.Trade) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.process(trades, true)
+}
+
+// 去除重复成交后交给 TradeHandler 并计入数据流, 返回处理的成交数量
+func (c *Client) process(trades []market.Trade, notify bool) int {
+	trades = c.filter(trades)
+	if c.tradeHandler != nil && len(trades) > 0 {
+		c.tradeHandler(trades)
+	}
+	c.push(trades, notify)
+	return len(trades)
+}
+
+// 使用已保存的成交恢复数据区间, 成交时间单位为毫秒, 不调用 Handler 及 TradeHandler. 在 Subscribe 之前调用
+func (c *Client) WarmTrades(trades []market.Trade) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 记录成交 id, 重连后重发的已恢复成交不会重复计入, 连接后从最近的成交开始补全
+	continuous := c.continuous
+	c.continuous = true
+	c.push(c.filter(trades), false)
+	c.continuous = continuous
+}
+
+// 去除已处理过的成交
+func (c *Client) filter(trades []market.Trade) []market.Trade {
+	before := c.dedupe.Duplicates()
+	trades = c.dedupe.Filter(trades)
+	if n := c.dedupe.Duplicates() - before; n > 0 {
+		metrics.DuplicateTrades.WithLabelValues(c.symbol).Add(float64(n))
+		c.statusMu.Lock()
+		c.status.Duplicates += n
+		c.statusMu.Unlock()
+	}
+	return trades
+}
+
+// 使用已保存的数据流恢复数据区间, 需按时间顺序, 不调用 Handler. 在 Subscribe 之前调用,
+// 连接后从最近的数据流结束时间开始补全
+func (c *Client) WarmFlows(flows []*Flow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, flow := range flows {
+		c.pushFlow(flow)
+	}
+}
+
+// 按成交时间将每笔成交计入所属的数据流, 然后结束最新成交时间已超过结束时间及宽限时间的数据流,
+// 与定时结束一样为 websocket 连接期间没有成交的数据流时长生成空数据流
+func (c *Client) push(trades []market.Trade, notify bool) {
+	var watermark int64
+	for _, t := range trades {
+		c.add(t)
+		if t.Timestamp > watermark {
+			watermark = t.Timestamp
+		}
+		if t.TradeId > c.lastTrade.TradeId {
+			c.lastTrade = t
+		}
+	}
+	if len(trades) > 0 {
+		c.price = trades[len(trades)-1].Price
+	}
+	c.closeUntil(watermark, notify)
+}
+
+// 生成空数据流的最早时间(单位: 秒), 为 0 时不生成
+func (c *Client) fillStart() int64 {
+	if c.continuous && c.closed > 0 {
+		return c.closed + c.flowDuration
+	}
+	return c.fillFrom
+}
+
+// 数据流开始时间为成交时间按数据流时长向下取整, 所属数据流已结束的成交计入最早未结束的数据流
+func (c *Client) add(t market.Trade) {
+	ts := t.Timestamp / 1000
+	start := ts - ts%c.flowDuration
+	if c.closed > 0 && start <= c.closed {
+		start = c.closed + c.flowDuration
+		metrics.LateTrades.WithLabelValues(c.symbol).Inc()
+	}
+	idx := len(c.open)
+	for i, flow := range c.open {
+		if flow.Timestamp >= start {
+			idx = i
+			break
+		}
+	}
+	if idx == len(c.open) || c.open[idx].Timestamp != start {
+		c.open = append(c.open, nil)
+		copy(c.open[idx+1:], c.open[idx:])
+		c.open[idx] = &Flow{Timestamp: start}
+	}
+	flow := c.open[idx]
+	if c.backfilling {
+		flow.Backfilled = true
+	}
+	cash := t.Price.Mul(t.Amount).IntPart()
+	if t.Direction == "buy" {
+		flow.Buy += cash
+		flow.Inflow += cash
+	} else {
+		flow.Sell += cash
+		flow.Inflow -= cash
+	}
+}
+
+// 按时间顺序结束结束时间加宽限时间不超过 watermark(单位: 毫秒) 的数据流, 需要时生成空数据流
+func (c *Client) closeUntil(watermark int64, notify bool) {
+	for {
+		var flow *Flow
+		if len(c.open) > 0 {
+			flow = c.open[0]
+		}
+		if fillFrom := c.fillStart(); fillFrom > 0 {
+			start := c.closed + c.flowDuration
+			if start < fillFrom {
+				start = fillFrom
+			}
+			if flow == nil || flow.Timestamp > start {
+				flow = &Flow{Timestamp: start}
+			}
+		}
+		if flow == nil || (flow.Timestamp+c.flowDuration)*1000+c.grace.Milliseconds() > watermark {
+			return
+		}
+		if len(c.open) > 0 && flow == c.open[0] {
+			c.open = c.open[1:]
+		}
+		c.closeFlow(flow, notify)
+	}
+}
+
+// 结束数据流, 加入各时长的数据区间
+func (c *Client) closeFlow(flow *Flow, notify bool) {
+	c.pushFlow(flow)
+	c.closing = flow
+	if notify {
+		c.handler(c.price, c)
+	}
+	c.closing = nil
+}
+
+// 数据流加入各时长的数据区间, 与最近结束的数据流之间缺少数据流(如断线或重启期间)或数据流可能缺少成交时,
+// 数据区间标记为不完整
+func (c *Client) pushFlow(flow *Flow) {
+	incomplete := flow.Timestamp <= c.gapAt
+	if incomplete || c.closed > 0 && flow.Timestamp > c.closed+c.flowDuration {
+		c.resetStart()
+	}
+	for _, container := range c.containers {
+		container.push(flow)
+	}
+	// 从下一个数据流开始重新累计
+	if incomplete {
+		c.resetStart()
+	}
+	c.closed = flow.Timestamp
+}
+
+// 按数据流时长对齐的时间加宽限时间定时结束数据流, 避免成交稀少时数据流过长、数据区间中的旧数据无法过期.
+// websocket 已连接时为没有成交的数据流时长生成空数据流
+func (c *Client) tick(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	size := c.flowDuration * 1000
+	for {
+		grace := c.gracePeriod().Milliseconds()
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		next := (now-grace)/size*size + size + grace
+		timer := time.NewTimer(time.Duration(next-now) * time.Millisecond)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		c.flush(next)
+	}
 }
 
+// 结束 watermark(单位: 毫秒) 之前可以结束的数据流. 断线期间不结束数据流, 等待重连后补全的成交计入所属的数据流
+func (c *Client) flush(watermark int64) {
+	if !c.Status().Connected {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeUntil(watermark, true)
+}
+
+// 使用默认连接配置订阅成交
 func Subscribe(symbol, clientId string, handler func(response market.SubscribeTradeResponse)) (closeFunc func()) {
-	client := new(marketwebsocketclient.TradeWebSocketClient).Init(config.Host)
+	return subscribe(DefaultEndpoint(), symbol, clientId, nil, nil, nil, handler)
+}
 
-	client.SetHandler(
-		func() {
-			client.Subscribe(symbol, clientId)
-		},
-		func(resp interface{}) {
-			response, ok := resp.(market.SubscribeTradeResponse)
-			if ok {
-				if &response != nil {
-					handler(response)
-				}
-			} else {
-				applogger.Warn("symbol %s.%s got unknown response: %v", symbol, clientId, resp)
+// 订阅成交, 每次连接成功后在发送订阅请求前调用 onConnected, 连接断开后调用 onDisconnected, 收到服务端 ping 时调用 onPing
+func subscribe(endpoint *Endpoint, symbol, clientId string, onConnected, onDisconnected, onPing func(), handler func(response market.SubscribeTradeResponse)) (closeFunc func()) {
+	// 首次连接之后的连接为重连
+	connected := false
+	socket := &tradeSocket{
+		endpoint: endpoint,
+		symbol:   symbol,
+		clientId: clientId,
+		onConnected: func() {
+			if connected {
+				metrics.WebsocketReconnects.WithLabelValues(symbol).Inc()
+			}
+			connected = true
+			if onConnected != nil {
+				onConnected()
 			}
 		},
-	)
-
-	client.Connect(true)
+		onDisconnected: onDisconnected,
+		onPing:         onPing,
+		handler:        handler,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go socket.run()
 	return func() {
-		client.UnSubscribe(symbol, clientId)
+		socket.close()
 		applogger.Info("symbol %s unsubscribed", symbol)
-		client.Close()
-		applogger.Info("client %s closed", clientId)
 	}
 }
@@ -0,0 +1,398 @@
+package flow
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+	"huobi/metrics"
+)
+
+// 测试用客户端, 记录每次结束的数据流
+type testClient struct {
+	*Client
+	closed []Flow
+}
+
+func newTestClient(flowDuration int64, durations []int64, grace time.Duration) *testClient {
+	tc := &testClient{Client: NewClient("test", "testusdt", flowDuration)}
+	tc.SetGracePeriod(grace)
+	tc.Listen(durations, func(price decimal.Decimal, sectionGetter SectionGetter) {
+		tc.closed = append(tc.closed, *sectionGetter.Flow())
+	})
+	return tc
+}
+
+// 模拟 websocket 已连接, fillFrom 为连接后第一个完整数据流的开始时间(单位: 秒)
+func (tc *testClient) connect(fillFrom int64) {
+	tc.statusMu.Lock()
+	tc.status.ConnectedAt = time.Now().Unix()
+	tc.online = true
+	tc.statusMu.Unlock()
+	tc.mu.Lock()
+	tc.fillFrom = fillFrom
+	tc.mu.Unlock()
+}
+
+// 已结束数据流的开始时间
+func (tc *testClient) timestamps() []int64 {
+	ts := make([]int64, 0, len(tc.closed))
+	for _, flow := range tc.closed {
+		ts = append(ts, flow.Timestamp)
+	}
+	return ts
+}
+
+// 成交额为 10 的买入成交, ts 单位为毫秒
+func buy(id, ts int64) market.Trade {
+	return market.Trade{
+		TradeId:   id,
+		Price:     decimal.NewFromInt(1),
+		Amount:    decimal.NewFromInt(10),
+		Direction: "buy",
+		Timestamp: ts,
+	}
+}
+
+func sell(id, ts int64) market.Trade {
+	t := buy(id, ts)
+	t.Direction = "sell"
+	return t
+}
+
+// 推送成交或定时结束数据流, flush 大于 0 时为定时结束的时间(单位: 毫秒)
+type step struct {
+	trades []market.Trade
+	flush  int64
+}
+
+func run(tc *testClient, steps []step) {
+	for _, s := range steps {
+		if s.flush > 0 {
+			tc.flush(s.flush)
+		} else {
+			tc.Push(s.trades)
+		}
+	}
+}
+
+func TestClientCloseFlows(t *testing.T) {
+	tests := []struct {
+		name     string
+		fillFrom int64 // 为 0 时模拟未连接
+		steps    []step
+		want     []int64
+		wantBuy  []int64
+	}{
+		{
+			name:  "grid aligned buckets",
+			steps: []step{{trades: []market.Trade{buy(1, 100000), buy(2, 109999), buy(3, 110000), buy(4, 120999)}}},
+			want:  []int64{100},
+			// 110 的结束时间加宽限时间为 121000, 尚未超过
+			wantBuy: []int64{20},
+		},
+		{
+			name:     "quiet period emitted by timer",
+			fillFrom: 100,
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{flush: 141000},
+			},
+			want:    []int64{100, 110, 120, 130},
+			wantBuy: []int64{10, 0, 0, 0},
+		},
+		{
+			name:     "quiet period emitted by trades",
+			fillFrom: 100,
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{trades: []market.Trade{buy(2, 135000)}},
+				{trades: []market.Trade{buy(3, 152000)}},
+			},
+			want:    []int64{100, 110, 120, 130, 140},
+			wantBuy: []int64{10, 0, 0, 10, 0},
+		},
+		{
+			name:     "timer and trades interleaved",
+			fillFrom: 100,
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{flush: 111000},
+				{trades: []market.Trade{buy(2, 145000)}},
+				{flush: 151000},
+			},
+			want:    []int64{100, 110, 120, 130, 140},
+			wantBuy: []int64{10, 0, 0, 0, 10},
+		},
+		{
+			name:     "empty buckets before connection are skipped",
+			fillFrom: 130,
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{trades: []market.Trade{buy(2, 152000)}},
+			},
+			want:    []int64{100, 130, 140},
+			wantBuy: []int64{10, 0, 0},
+		},
+		{
+			name: "disconnected",
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{flush: 200000},
+				{trades: []market.Trade{buy(2, 152000)}},
+			},
+			want:    []int64{100},
+			wantBuy: []int64{10},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newTestClient(10, []int64{30}, time.Second)
+			if test.fillFrom > 0 {
+				tc.connect(test.fillFrom)
+			}
+			run(tc, test.steps)
+			if got := tc.timestamps(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("closed flows = %v, want %v", got, test.want)
+			}
+			for i, flow := range tc.closed {
+				if flow.Buy != test.wantBuy[i] {
+					t.Fatalf("flow %d Buy = %d, want %d", flow.Timestamp, flow.Buy, test.wantBuy[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClientSection(t *testing.T) {
+	tc := newTestClient(10, []int64{30}, 0)
+	tc.connect(100)
+	tc.Push([]market.Trade{buy(1, 100000), sell(2, 115000), buy(3, 125000), buy(4, 135000)})
+	tc.flush(160000)
+	section := tc.GetSection(30)
+	// 数据区间包含 120 至 150 的数据流, 100 及 110 已过期
+	want := Section{Buy: 20, Sell: 0, Inflow: 20, StartTime: 120, EndTime: 150, Partial: false}
+	if *section != want {
+		t.Fatalf("section = %+v, want %+v", *section, want)
+	}
+}
+
+func TestClientFlushWithoutTrades(t *testing.T) {
+	tc := newTestClient(10, []int64{30}, 0)
+	tc.connect(100)
+	// 连接及最近一次推送均早于 PingTimeout, 没有成交的交易对只会收到服务端 ping
+	tc.statusMu.Lock()
+	tc.status.ConnectedAt = time.Now().Unix() - 120
+	tc.status.LastMessageAt = time.Now().Unix() - 120
+	tc.statusMu.Unlock()
+	tc.flush(141000)
+	if len(tc.closed) != 0 {
+		t.Fatalf("closed %v without ping, want none", tc.timestamps())
+	}
+	tc.onPing()
+	if !tc.Status().Connected {
+		t.Fatal("Status().Connected = false after ping")
+	}
+	tc.flush(141000)
+	if want := []int64{100, 110, 120, 130}; !reflect.DeepEqual(tc.timestamps(), want) {
+		t.Fatalf("closed %v, want %v", tc.timestamps(), want)
+	}
+}
+
+func TestClientSnapshotFlows(t *testing.T) {
+	tc := newTestClient(10, []int64{30}, 2*time.Second)
+	tc.connect(100)
+	// 宽限时间内 100 的数据流尚未结束, 110 的数据流已开始
+	tc.Push([]market.Trade{buy(1, 105000), sell(2, 111000)})
+	snapshot := tc.Snapshot()
+	if len(snapshot.Flows) != 2 || snapshot.Flows[0].Timestamp != 100 || snapshot.Flows[1].Timestamp != 110 {
+		t.Fatalf("Flows = %+v, want open flows 100 and 110", snapshot.Flows)
+	}
+	if snapshot.Flow.Timestamp != 110 || snapshot.Flow.Sell != 10 {
+		t.Fatalf("Flow = %+v, want newest flow 110", snapshot.Flow)
+	}
+}
+
+func TestClientLateTrades(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []step
+		want     []int64
+		wantBuy  []int64
+		wantLate float64
+	}{
+		{
+			name: "trade at bucket start",
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{flush: 111000},
+				{trades: []market.Trade{buy(2, 110000)}},
+				{flush: 121000},
+			},
+			want:    []int64{100, 110},
+			wantBuy: []int64{10, 10},
+		},
+		{
+			name: "trade within grace period",
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{trades: []market.Trade{buy(2, 110999)}},
+				{trades: []market.Trade{buy(3, 109999)}},
+				{flush: 121000},
+			},
+			want:    []int64{100, 110},
+			wantBuy: []int64{20, 10},
+		},
+		{
+			name: "trade at boundary plus grace",
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				// 同一批成交先计入数据流再结束
+				{trades: []market.Trade{buy(2, 109999), buy(3, 111000)}},
+				{trades: []market.Trade{buy(4, 109999)}},
+				{flush: 121000},
+			},
+			want:     []int64{100, 110},
+			wantBuy:  []int64{20, 20},
+			wantLate: 1,
+		},
+		{
+			name: "trade after flush",
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{flush: 111000},
+				{trades: []market.Trade{buy(2, 105000)}},
+				{flush: 121000},
+			},
+			want:     []int64{100, 110},
+			wantBuy:  []int64{10, 10},
+			wantLate: 1,
+		},
+		{
+			name: "trade after empty buckets",
+			steps: []step{
+				{trades: []market.Trade{buy(1, 100500)}},
+				{flush: 131000},
+				{trades: []market.Trade{buy(2, 105000), buy(3, 115000)}},
+				{flush: 141000},
+			},
+			want:     []int64{100, 110, 120, 130},
+			wantBuy:  []int64{10, 0, 0, 20},
+			wantLate: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newTestClient(10, []int64{30}, time.Second)
+			tc.connect(100)
+			late := metrics.LateTrades.WithLabelValues(tc.symbol)
+			before := testutil.ToFloat64(late)
+			run(tc, test.steps)
+			if got := tc.timestamps(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("closed flows = %v, want %v", got, test.want)
+			}
+			for i, flow := range tc.closed {
+				if flow.Buy != test.wantBuy[i] {
+					t.Fatalf("flow %d Buy = %d, want %d", flow.Timestamp, flow.Buy, test.wantBuy[i])
+				}
+			}
+			if got := testutil.ToFloat64(late) - before; got != test.wantLate {
+				t.Fatalf("late trades = %v, want %v", got, test.wantLate)
+			}
+		})
+	}
+}
+
+// 恢复的数据流, 开始时间为 from 至 to(单位: 秒)
+func warmFlows(from, to, flowDuration int64) []*Flow {
+	var flows []*Flow
+	for ts := from; ts <= to; ts += flowDuration {
+		flows = append(flows, &Flow{Buy: 10, Inflow: 10, Timestamp: ts})
+	}
+	return flows
+}
+
+func TestClientWarmUpGap(t *testing.T) {
+	tests := []struct {
+		name        string
+		fillFrom    int64 // 重启后连接成功的时间
+		wantPartial []bool
+	}{
+		{name: "no gap", fillFrom: 200, wantPartial: []bool{false, false, false, false}},
+		{name: "one bucket missing", fillFrom: 210, wantPartial: []bool{true, true, true, false}},
+		{name: "many buckets missing", fillFrom: 500, wantPartial: []bool{true, true, true, false}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tc := newTestClient(10, []int64{30}, time.Second)
+			var partial []bool
+			tc.Listen(nil, func(price decimal.Decimal, sectionGetter SectionGetter) {
+				partial = append(partial, sectionGetter.GetSection(30).Partial)
+			})
+			tc.WarmFlows(warmFlows(100, 190, 10))
+			if tc.GetSection(30).Partial {
+				t.Fatalf("warmed section should be complete")
+			}
+			tc.connect(test.fillFrom)
+			tc.flush((test.fillFrom+40)*1000 + 1000)
+			if !reflect.DeepEqual(partial, test.wantPartial) {
+				t.Fatalf("partial = %v, want %v", partial, test.wantPartial)
+			}
+		})
+	}
+}
+
+func TestClientWarmTrades(t *testing.T) {
+	tc := newTestClient(10, []int64{20}, time.Second)
+	// 保存的成交之间没有成交的时长生成空数据流, 不视为缺失
+	tc.WarmTrades([]market.Trade{buy(1, 100500), buy(2, 135000)})
+	if got := tc.timestamps(); len(got) != 0 {
+		t.Fatalf("warm up should not call Handler, got %v", got)
+	}
+	section := tc.GetSection(20)
+	if section.EndTime != 120 || section.Partial {
+		t.Fatalf("section = %+v, want complete section ending at 120", *section)
+	}
+	if tc.lastTrade.TradeId != 2 {
+		t.Fatalf("lastTrade = %d, want 2", tc.lastTrade.TradeId)
+	}
+	// 重连后的推送重发已恢复的成交
+	tc.connect(200)
+	tc.Push([]market.Trade{buy(2, 135000), buy(3, 200500)})
+	tc.flush(211000)
+	if got := tc.timestamps(); !reflect.DeepEqual(got, []int64{130, 200}) {
+		t.Fatalf("closed flows = %v, want [130 200]", got)
+	}
+	if tc.closed[0].Buy != 10 {
+		t.Fatalf("flow 130 Buy = %d, want 10", tc.closed[0].Buy)
+	}
+	if !tc.GetSection(20).Partial {
+		t.Fatalf("section after restart gap should be partial")
+	}
+}
+
+func TestClientImport(t *testing.T) {
+	tc := newTestClient(10, []int64{20}, time.Second)
+	tc.SetContinuous(true)
+	for i, ts := range []int64{100500, 101000, 135000, 136000} {
+		tc.Push([]market.Trade{buy(int64(i+1), ts)})
+	}
+	tc.CloseFlows()
+	if got := tc.timestamps(); !reflect.DeepEqual(got, []int64{100, 110, 120, 130}) {
+		t.Fatalf("closed flows = %v, want [100 110 120 130]", got)
+	}
+	if tc.closed[3].Buy != 20 {
+		t.Fatalf("last flow Buy = %d, want 20", tc.closed[3].Buy)
+	}
+	// 历史成交中没有成交的时长不视为缺失
+	if tc.GetSection(20).Partial {
+		t.Fatalf("imported section should be complete")
+	}
+	tc.CloseFlows()
+	if len(tc.closed) != 4 {
+		t.Fatalf("CloseFlows without open flows should not close anything")
+	}
+}
@@ -0,0 +1,67 @@
+package flow
+
+import (
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+)
+
+// 默认记录的成交 id 数量, 足以覆盖重连后服务端重发的成交
+const DefaultDedupeSize = 10000
+
+// 按成交 id 去除重复成交, 只记录最近的 size 个成交 id, 超出后按加入顺序淘汰. 非并发安全, 由调用方加锁
+type Deduper struct {
+	seen       map[int64]struct{}
+	ids        []int64 // 环形缓冲区, 按加入顺序保存成交 id
+	next       int
+	duplicates int64
+}
+
+// size 不大于 0 时使用 DefaultDedupeSize
+func NewDeduper(size int) *Deduper {
+	if size <= 0 {
+		size = DefaultDedupeSize
+	}
+	return &Deduper{
+		seen: make(map[int64]struct{}, size),
+		ids:  make([]int64, 0, size),
+	}
+}
+
+// 记录成交 id, 已记录过时返回 true
+func (d *Deduper) Seen(tradeId int64) bool {
+	if _, ok := d.seen[tradeId]; ok {
+		d.duplicates++
+		return true
+	}
+	if len(d.ids) < cap(d.ids) {
+		d.ids = append(d.ids, tradeId)
+	} else {
+		delete(d.seen, d.ids[d.next])
+		d.ids[d.next] = tradeId
+		d.next = (d.next + 1) % len(d.ids)
+	}
+	d.seen[tradeId] = struct{}{}
+	return false
+}
+
+// 返回未重复的成交, 没有重复时返回原切片
+func (d *Deduper) Filter(trades []market.Trade) []market.Trade {
+	for i, t := range trades {
+		if !d.Seen(t.TradeId) {
+			continue
+		}
+		// 出现重复时复制之前的成交, 不修改原切片
+		filtered := append([]market.Trade(nil), trades[:i]...)
+		for _, t := range trades[i+1:] {
+			if !d.Seen(t.TradeId) {
+				filtered = append(filtered, t)
+			}
+		}
+		return filtered
+	}
+	return trades
+}
+
+// 累计丢弃的重复成交数量
+func (d *Deduper) Duplicates() int64 {
+	return d.duplicates
+}
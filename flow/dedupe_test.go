@@ -0,0 +1,70 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+)
+
+func TestDeduperSeen(t *testing.T) {
+	d := NewDeduper(3)
+	steps := []struct {
+		id         int64
+		seen       bool
+		duplicates int64
+	}{
+		{1, false, 0},
+		{2, false, 0},
+		{1, true, 1}, // 命中
+		{3, false, 1},
+		{4, false, 1}, // 已满, 淘汰最早加入的 1
+		{2, true, 2},  // 2 仍在记录中
+		{1, false, 2}, // 1 已被淘汰, 重新加入并淘汰 2
+		{2, false, 2}, // 2 已被淘汰, 重新加入并淘汰 3
+		{3, false, 2}, // 3 已被淘汰, 重新加入并淘汰 4
+		{4, false, 2},
+		{1, false, 2}, // 环形缓冲区第二次回绕后 1 被淘汰
+		{3, true, 3},
+	}
+	for i, step := range steps {
+		if seen := d.Seen(step.id); seen != step.seen {
+			t.Fatalf("step %d: Seen(%d) = %v, want %v", i, step.id, seen, step.seen)
+		}
+		if got := d.Duplicates(); got != step.duplicates {
+			t.Fatalf("step %d: Duplicates() = %d, want %d", i, got, step.duplicates)
+		}
+	}
+}
+
+func TestDeduperDefaultSize(t *testing.T) {
+	d := NewDeduper(0)
+	for id := int64(0); id < DefaultDedupeSize; id++ {
+		d.Seen(id)
+	}
+	if !d.Seen(0) {
+		t.Fatalf("id 0 should still be recorded within DefaultDedupeSize")
+	}
+	d.Seen(DefaultDedupeSize) // 淘汰最早加入的 0
+	if d.Seen(0) {
+		t.Fatalf("id 0 should have been evicted")
+	}
+}
+
+func TestDeduperFilter(t *testing.T) {
+	d := NewDeduper(10)
+	first := []market.Trade{{TradeId: 1}, {TradeId: 2}}
+	if got := d.Filter(first); len(got) != 2 || &got[0] != &first[0] {
+		t.Fatalf("Filter without duplicates should return the original slice")
+	}
+	batch := []market.Trade{{TradeId: 2}, {TradeId: 3}, {TradeId: 3}, {TradeId: 4}}
+	got := d.Filter(batch)
+	if len(got) != 2 || got[0].TradeId != 3 || got[1].TradeId != 4 {
+		t.Fatalf("Filter() = %v, want trades 3 and 4", got)
+	}
+	if batch[0].TradeId != 2 || batch[1].TradeId != 3 || batch[2].TradeId != 3 {
+		t.Fatalf("Filter must not modify the input slice: %v", batch)
+	}
+	if d.Duplicates() != 2 {
+		t.Fatalf("Duplicates() = %d, want 2", d.Duplicates())
+	}
+}
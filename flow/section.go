@@ -5,6 +5,7 @@ type container struct {
 	section  *Section
 	duration int64
 	flows    []*Flow
+	start    int64 // 第一个数据流的时间, 用于判断数据区间是否完整
 }
 
 func newContainer(duration int64) *container {
@@ -18,6 +19,11 @@ func newContainer(duration int64) *container {
 func (c *container) push(flow *Flow) {
 	// 更新数据结束时间
 	c.section.EndTime = flow.Timestamp
+	if c.start == 0 {
+		c.start = flow.Timestamp
+	}
+	// 启动或新增时长后累计的数据不足 duration 时数据区间不完整
+	c.section.Partial = flow.Timestamp-c.start < c.duration
 
 	// 最小时间，超过最小时间的旧数据将被删除
 	minTime := flow.Timestamp - c.duration
@@ -56,6 +62,7 @@ type Section struct {
 	Inflow    int64
 	StartTime int64
 	EndTime   int64
+	Partial   bool // 累计的数据未完整覆盖数据区间时长
 }
 
 // 数据流
@@ -64,4 +71,6 @@ type Flow struct {
 	Sell      int64
 	Inflow    int64
 	Timestamp int64
+	// 包含断线后通过 REST 接口补全的成交
+	Backfilled bool
 }
@@ -0,0 +1,213 @@
+package flow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 行情 websocket 连接配置
+type Endpoint struct {
+	URL           string        // websocket 地址, 如 wss://api.huobi.pro/ws
+	PingTimeout   time.Duration // 超过该时间未收到服务端的 ping 或推送时断开重连
+	PongTimeout   time.Duration // 回复 pong 及发送订阅请求的超时时间
+	ReconnectMin  time.Duration // 首次重连的等待时间, 之后每次失败加倍
+	ReconnectMax  time.Duration // 重连的最长等待时间
+	AutoReconnect bool          // 连接断开后是否自动重连
+}
+
+// 默认连接火币行情服务
+func DefaultEndpoint() *Endpoint {
+	return &Endpoint{
+		URL:           "wss://api.huobi.pro/ws",
+		PingTimeout:   60 * time.Second,
+		PongTimeout:   10 * time.Second,
+		ReconnectMin:  time.Second,
+		ReconnectMax:  60 * time.Second,
+		AutoReconnect: true,
+	}
+}
+
+// 订阅逐笔成交的 websocket 客户端, 连接断开后按配置等待后重连, 重连后重新订阅
+type tradeSocket struct {
+	endpoint       *Endpoint
+	symbol         string
+	clientId       string
+	onConnected    func()
+	onDisconnected func()
+	onPing         func() // 收到服务端 ping, 没有成交时用于判断连接是否正常
+	handler        func(response market.SubscribeTradeResponse)
+	conn           *websocket.Conn // 未连接时为 nil
+	mu             sync.Mutex      // 保护 conn 及 stopped, 同时保证同一时间只有一个协程写入
+	stopped        bool
+	stop           chan struct{}
+	done           chan struct{}
+}
+
+// 服务端消息, 行情推送及 ping 均经过 gzip 压缩
+type socketMessage struct {
+	Ping   int64           `json:"ping"`
+	Status string          `json:"status"`
+	ErrMsg string          `json:"err-msg"`
+	Tick   json.RawMessage `json:"tick"`
+}
+
+func (s *tradeSocket) topic() string {
+	return fmt.Sprintf("market.%s.trade.detail", s.symbol)
+}
+
+// 后台连接并订阅, 直到调用 close 或连接断开且不自动重连
+func (s *tradeSocket) run() {
+	defer close(s.done)
+	wait := s.endpoint.ReconnectMin
+	for {
+		connected, err := s.session()
+		if s.isStopped() {
+			return
+		}
+		if !s.endpoint.AutoReconnect {
+			applogger.Error("symbol %s websocket closed: %s", s.symbol, err)
+			return
+		}
+		if connected {
+			wait = s.endpoint.ReconnectMin
+		}
+		applogger.Warn("symbol %s websocket closed: %s, reconnect in %s", s.symbol, err, wait)
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+		if wait > s.endpoint.ReconnectMax {
+			wait = s.endpoint.ReconnectMax
+		}
+	}
+}
+
+// 建立连接并订阅, 读取消息直到连接断开. connected 表示是否连接成功
+func (s *tradeSocket) session() (connected bool, err error) {
+	dialer := &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: s.endpoint.PingTimeout}
+	conn, _, err := dialer.Dial(s.endpoint.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		conn.Close()
+		return false, nil
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		conn.Close()
+		if s.onDisconnected != nil {
+			s.onDisconnected()
+		}
+	}()
+
+	applogger.Info("symbol %s websocket connected to %s", s.symbol, s.endpoint.URL)
+	if s.onConnected != nil {
+		s.onConnected()
+	}
+	err = s.send(conn, fmt.Sprintf(`{"sub": "%s", "id": "%s"}`, s.topic(), s.clientId))
+	if err != nil {
+		return true, err
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.endpoint.PingTimeout))
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		if msgType == websocket.BinaryMessage {
+			data, err = gunzip(data)
+			if err != nil {
+				return true, err
+			}
+		}
+		err = s.handle(conn, data)
+		if err != nil {
+			return true, err
+		}
+	}
+}
+
+func (s *tradeSocket) handle(conn *websocket.Conn, data []byte) error {
+	msg := &socketMessage{}
+	err := json.Unmarshal(data, msg)
+	if err != nil {
+		applogger.Warn("symbol %s got unknown message: %s", s.symbol, data)
+		return nil
+	}
+	switch {
+	case msg.Ping != 0:
+		if s.onPing != nil {
+			s.onPing()
+		}
+		return s.send(conn, fmt.Sprintf(`{"pong": %d}`, msg.Ping))
+	case msg.Status == "error":
+		applogger.Error("symbol %s.%s got error: %s", s.symbol, s.clientId, msg.ErrMsg)
+	case len(msg.Tick) > 0:
+		response := market.SubscribeTradeResponse{}
+		err = json.Unmarshal(data, &response)
+		if err != nil {
+			applogger.Warn("symbol %s got unknown response: %s", s.symbol, data)
+			return nil
+		}
+		s.handler(response)
+	}
+	return nil
+}
+
+func (s *tradeSocket) send(conn *websocket.Conn, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(s.endpoint.PongTimeout))
+	return conn.WriteMessage(websocket.TextMessage, []byte(msg))
+}
+
+func (s *tradeSocket) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// 取消订阅并关闭连接, 等待正在处理的推送完成后返回
+func (s *tradeSocket) close() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	close(s.stop)
+	if s.conn != nil {
+		s.conn.SetWriteDeadline(time.Now().Add(s.endpoint.PongTimeout))
+		s.conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"unsub": "%s", "id": "%s"}`, s.topic(), s.clientId)))
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,63 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+)
+
+func TestSocketPing(t *testing.T) {
+	pong := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// 订阅请求
+		_, _, err = conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"ping": 1609459200000}`))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		pong <- string(data)
+		// 保持连接直到客户端关闭
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	pinged := make(chan struct{}, 1)
+	endpoint := &Endpoint{
+		URL:          "ws" + strings.TrimPrefix(server.URL, "http"),
+		PingTimeout:  5 * time.Second,
+		PongTimeout:  5 * time.Second,
+		ReconnectMin: time.Second,
+		ReconnectMax: time.Second,
+	}
+	closeFunc := subscribe(endpoint, "testusdt", "test", nil, nil, func() {
+		pinged <- struct{}{}
+	}, func(response market.SubscribeTradeResponse) {})
+	defer closeFunc()
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onPing was not called")
+	}
+	select {
+	case data := <-pong:
+		if data != `{"pong": 1609459200000}` {
+			t.Fatalf("pong = %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pong was not sent")
+	}
+}
@@ -3,12 +3,18 @@ module huobi
 go 1.15
 
 require (
-	github.com/gin-contrib/cors v1.3.1 // indirect
+	github.com/BurntSushi/toml v0.3.1
+	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-gonic/gin v1.6.3
+	github.com/gorilla/websocket v1.4.1
 	github.com/huobirdcenter/huobi_golang v0.0.0-20201231082458-10d97afd26d8
 	github.com/morgine/pkg v0.0.0-20210104083822-6aaa329258a5
+	github.com/prometheus/client_golang v1.9.0
 	github.com/shopspring/decimal v1.2.0
+	github.com/xitongsys/parquet-go v1.5.4
+	github.com/xitongsys/parquet-go-source v0.0.0-20201108113611-f372b7d813be // indirect
 	go.uber.org/zap v1.15.0
+	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.20.7
 )
 
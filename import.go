@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"github.com/morgine/pkg/config"
+	config2 "huobi/config"
+	"huobi/flow"
+	"huobi/importer"
+	"huobi/model"
+	"huobi/routes"
+	"time"
+)
+
+// 导入历史成交数据文件, 按订阅时相同的方式计算并写入数据区间, 结束时间已存在的数据区间跳过:
+//
+//	import -symbol xrpusdt [-format binance|huobi] [-trades] 文件...
+func runImport(configs config.Configs, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	symbol := fs.String("symbol", "", "交易对")
+	format := fs.String("format", importer.FormatBinance, "文件格式: binance, huobi")
+	storeTrades := fs.Bool("trades", false, "同时保存逐笔成交")
+	batchSize := fs.Int("batch", 1000, "单次批量写入的最大数据量")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *symbol == "" {
+		return errors.New("import: -symbol is required")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("import: no files given")
+	}
+	storageConfig, err := config2.NewStorage("storage", configs)
+	if err != nil {
+		return err
+	}
+	// 使用配置文件中该交易对的存储、数据流及数据区间时长
+	subscribe, err := config2.FindSubscribe(configs, storageConfig, *symbol)
+	if err != nil {
+		return err
+	}
+	storage, err := routes.NewStorage(subscribe.Storage, *symbol, configs)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+	if schema, ok := storage.(model.SchemaStorage); ok {
+		err = schema.CheckSchema()
+		if err != nil {
+			return err
+		}
+	}
+
+	// 导入时同步写入, 写入失败立即停止
+	var writeErr error
+	var sections []*model.Section
+	var trades []*model.Trade
+	var totalSections, skippedSections, totalTrades int64
+	flushSections := func() {
+		if writeErr == nil && len(sections) > 0 {
+			// 重复导入相同时间段时跳过结束时间已存在的数据区间
+			var unwritten []*model.Section
+			unwritten, writeErr = model.UnwrittenSections(storage, sections)
+			if writeErr == nil && len(unwritten) > 0 {
+				writeErr = storage.CreateSections(unwritten)
+			}
+			if writeErr == nil {
+				totalSections += int64(len(unwritten))
+				skippedSections += int64(len(sections) - len(unwritten))
+			}
+			sections = sections[:0]
+		}
+	}
+	flushTrades := func() {
+		if writeErr == nil && len(trades) > 0 {
+			writeErr = storage.CreateTrades(trades)
+			trades = trades[:0]
+		}
+	}
+
+	client := flow.NewClient("", *symbol, subscribe.BucketSize)
+	client.SetGracePeriod(time.Duration(subscribe.GracePeriod) * time.Millisecond)
+	client.SetContinuous(true)
+	routes.ListenSections(client, subscribe.Windows, func(section *model.Section) {
+		sections = append(sections, section)
+		if len(sections) >= *batchSize {
+			flushSections()
+		}
+	})
+	if *storeTrades {
+		routes.ListenTrades(client, func(trade *model.Trade) {
+			trades = append(trades, trade)
+			if len(trades) >= *batchSize {
+				flushTrades()
+			}
+		})
+	}
+	err = importer.ReadFiles(fs.Args(), *format, func(t market.Trade) error {
+		// 逐笔处理, 保证每笔成交都能触发数据流的结束判断
+		client.Push([]market.Trade{t})
+		totalTrades++
+		if totalTrades%1000000 == 0 {
+			applogger.Info("%s: %d trades imported", *symbol, totalTrades)
+		}
+		return writeErr
+	})
+	if err != nil {
+		return err
+	}
+	// 文件结束后不会再有成交, 结束最后的数据流
+	client.CloseFlows()
+	flushSections()
+	flushTrades()
+	if writeErr != nil {
+		return writeErr
+	}
+	fmt.Printf("%s: %d trades imported, %d sections written, %d existing sections skipped\n", *symbol, totalTrades, totalSections, skippedSections)
+	return nil
+}
@@ -0,0 +1,195 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"github.com/shopspring/decimal"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 成交数据文件格式
+const (
+	// 币安每日成交数据: id,price,qty,quote_qty,time,is_buyer_maker[,is_best_match]
+	FormatBinance = "binance"
+	// 火币每日成交数据: id,ts,price,amount,direction
+	FormatHuobi = "huobi"
+)
+
+// 读取成交数据文件, 文件按名称排序后依次读取, 每笔成交转换为与订阅推送相同的格式后交给 handle.
+// 支持 zip 压缩包(包含一个或多个 csv 文件)及未压缩的 csv 文件, 表头行将被跳过
+func ReadFiles(files []string, format string, handle func(t market.Trade) error) error {
+	var parse func(record []string) (market.Trade, error)
+	switch format {
+	case FormatBinance:
+		parse = parseBinance
+	case FormatHuobi:
+		parse = parseHuobi
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+	files = append([]string(nil), files...)
+	sort.Strings(files)
+	for _, file := range files {
+		var err error
+		if strings.EqualFold(filepath.Ext(file), ".zip") {
+			err = readZip(file, parse, handle)
+		} else {
+			err = readFile(file, parse, handle)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func readZip(file string, parse func(record []string) (market.Trade, error), handle func(t market.Trade) error) error {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	entries := append([]*zip.File(nil), r.File...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	for _, entry := range entries {
+		if !strings.EqualFold(filepath.Ext(entry.Name), ".csv") {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = readCSV(rc, parse, handle)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+func readFile(file string, parse func(record []string) (market.Trade, error), handle func(t market.Trade) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readCSV(f, parse, handle)
+}
+
+func readCSV(r io.Reader, parse func(record []string) (market.Trade, error), handle func(t market.Trade) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// 表头行
+		if line == 1 && !isNumber(record[0]) {
+			continue
+		}
+		t, err := parse(record)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		err = handle(t)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func parseBinance(record []string) (t market.Trade, err error) {
+	if len(record) < 6 {
+		return t, fmt.Errorf("expect at least 6 fields, got %d", len(record))
+	}
+	t.TradeId, err = strconv.ParseInt(record[0], 10, 64)
+	if err != nil {
+		return t, err
+	}
+	t.Price, err = decimal.NewFromString(record[1])
+	if err != nil {
+		return t, err
+	}
+	t.Amount, err = decimal.NewFromString(record[2])
+	if err != nil {
+		return t, err
+	}
+	t.Timestamp, err = parseTimestamp(record[4])
+	if err != nil {
+		return t, err
+	}
+	// 买方为挂单方时主动成交方为卖方
+	isBuyerMaker, err := strconv.ParseBool(record[5])
+	if err != nil {
+		return t, err
+	}
+	if isBuyerMaker {
+		t.Direction = "sell"
+	} else {
+		t.Direction = "buy"
+	}
+	return t, nil
+}
+
+func parseHuobi(record []string) (t market.Trade, err error) {
+	if len(record) < 5 {
+		return t, fmt.Errorf("expect at least 5 fields, got %d", len(record))
+	}
+	t.TradeId, err = strconv.ParseInt(record[0], 10, 64)
+	if err != nil {
+		return t, err
+	}
+	t.Timestamp, err = parseTimestamp(record[1])
+	if err != nil {
+		return t, err
+	}
+	t.Price, err = decimal.NewFromString(record[2])
+	if err != nil {
+		return t, err
+	}
+	t.Amount, err = decimal.NewFromString(record[3])
+	if err != nil {
+		return t, err
+	}
+	t.Direction = strings.ToLower(record[4])
+	if t.Direction != "buy" && t.Direction != "sell" {
+		return t, fmt.Errorf("unknown direction %q", record[4])
+	}
+	return t, nil
+}
+
+// 统一转换为毫秒, 兼容以秒或微秒为单位的时间戳
+func parseTimestamp(s string) (int64, error) {
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case ts >= 1e14:
+		return ts / 1000, nil
+	case ts < 1e11:
+		return ts * 1000, nil
+	default:
+		return ts, nil
+	}
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
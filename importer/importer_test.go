@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(record []string) (market.Trade, error)
+		record  string
+		want    market.Trade
+		wantErr string
+	}{
+		{
+			name:   "binance buyer maker",
+			parse:  parseBinance,
+			record: "101,0.5123,200,102.46,1609459200123,True,True",
+			want:   market.Trade{TradeId: 101, Timestamp: 1609459200123, Direction: "sell"},
+		},
+		{
+			name:   "binance buyer taker",
+			parse:  parseBinance,
+			record: "102,0.5123,200,102.46,1609459200123,false",
+			want:   market.Trade{TradeId: 102, Timestamp: 1609459200123, Direction: "buy"},
+		},
+		{
+			name:   "binance microseconds",
+			parse:  parseBinance,
+			record: "103,0.5123,200,102.46,1609459200123456,false",
+			want:   market.Trade{TradeId: 103, Timestamp: 1609459200123, Direction: "buy"},
+		},
+		{
+			name:   "huobi",
+			parse:  parseHuobi,
+			record: "201,1609459200123,0.5123,200,buy",
+			want:   market.Trade{TradeId: 201, Timestamp: 1609459200123, Direction: "buy"},
+		},
+		{
+			name:   "huobi uppercase direction and seconds",
+			parse:  parseHuobi,
+			record: "202,1609459200,0.5123,200,SELL",
+			want:   market.Trade{TradeId: 202, Timestamp: 1609459200000, Direction: "sell"},
+		},
+		{name: "binance too few fields", parse: parseBinance, record: "101,0.5123,200,102.46,1609459200123", wantErr: "expect at least 6 fields"},
+		{name: "binance bad price", parse: parseBinance, record: "101,abc,200,102.46,1609459200123,true", wantErr: "abc"},
+		{name: "binance bad maker flag", parse: parseBinance, record: "101,0.5123,200,102.46,1609459200123,maybe", wantErr: "maybe"},
+		{name: "huobi too few fields", parse: parseHuobi, record: "201,1609459200123,0.5123,200", wantErr: "expect at least 5 fields"},
+		{name: "huobi bad timestamp", parse: parseHuobi, record: "201,yesterday,0.5123,200,buy", wantErr: "yesterday"},
+		{name: "huobi unknown direction", parse: parseHuobi, record: "201,1609459200123,0.5123,200,hold", wantErr: `unknown direction "hold"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.parse(strings.Split(test.record, ","))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("parse() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.TradeId != test.want.TradeId || got.Timestamp != test.want.Timestamp || got.Direction != test.want.Direction {
+				t.Fatalf("parse() = %+v, want %+v", got, test.want)
+			}
+			if got.Price.String() != "0.5123" || got.Amount.String() != "200" {
+				t.Fatalf("parse() price %s amount %s, want 0.5123 and 200", got.Price, got.Amount)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	err := ioutil.WriteFile(file, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	// 文件按名称排序后读取, 表头行跳过
+	second := writeFile(t, dir, "b.csv", "id,ts,price,amount,direction\n3,1609459202000,1,1,buy\n")
+	first := writeFile(t, dir, "a.csv", "1,1609459200000,1,1,buy\n2,1609459201000,1,1,sell\n")
+	zipFile := filepath.Join(dir, "c.zip")
+	f, err := os.Create(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, entry := range []struct{ name, content string }{
+		{"2.csv", "5,1609459204000,1,1,buy\n"},
+		{"readme.txt", "not a csv"},
+		{"1.csv", "4,1609459203000,1,1,sell\n"},
+	} {
+		ew, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ew.Write([]byte(entry.content))
+	}
+	w.Close()
+	f.Close()
+
+	var ids []int64
+	err = ReadFiles([]string{zipFile, second, first}, FormatHuobi, func(trade market.Trade) error {
+		ids = append(ids, trade.TradeId)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 5 {
+		t.Fatalf("read %v, want trades 1 to 5", ids)
+	}
+	for i, id := range ids {
+		if id != int64(i+1) {
+			t.Fatalf("read %v, want trades 1 to 5 in order", ids)
+		}
+	}
+}
+
+func TestReadFilesError(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "bad.csv", "1,1609459200000,1,1,buy\n2,1609459201000,1,1\n")
+	err := ReadFiles([]string{file}, FormatHuobi, func(trade market.Trade) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "bad.csv: line 2: expect at least 5 fields") {
+		t.Fatalf("ReadFiles() error = %v, want line number of the malformed row", err)
+	}
+	err = ReadFiles([]string{file}, "okx", func(trade market.Trade) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), `unsupported format "okx"`) {
+		t.Fatalf("ReadFiles() error = %v, want unsupported format", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/huobirdcenter/huobi_golang/pkg/client/marketwebsocketclient"
 	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
 	"github.com/shopspring/decimal"
+	"huobi/flow"
 	"sync"
 )
 
@@ -21,6 +22,7 @@ type Client struct {
 	maxQueues int   // 最大队列数量，超过该阈值则删除一部分老的数据
 	delQueues int   // 队列超过阈值时删除数据量
 	handlers  []QueueHandler
+	dedupe    *flow.Deduper // 去除重连后重发的成交
 	mu        sync.Mutex
 }
 
@@ -30,6 +32,8 @@ type ClientOptions struct {
 	Duration  int64 // 统计时差(毫秒)，如 10000 毫秒
 	MaxQueues int   // 最大队列数量，超过该阈值则删除一部分老的数据
 	DelQueues int   // 队列超过阈值时删除数据量
+	// 用于去重的成交 id 数量, 为 0 时使用 flow.DefaultDedupeSize
+	DedupeSize int
 }
 
 func NewClient(options *ClientOptions) *Client {
@@ -41,6 +45,7 @@ func NewClient(options *ClientOptions) *Client {
 		duration:  options.Duration,
 		maxQueues: options.MaxQueues,
 		delQueues: options.DelQueues,
+		dedupe:    flow.NewDeduper(options.DedupeSize),
 		mu:        sync.Mutex{},
 	}
 }
@@ -162,6 +167,13 @@ func (c *Client) GetInflows(startTime, durationSeconds int64) (cash, coins decim
 	return
 }
 
+// 丢弃的重复成交数量
+func (c *Client) Duplicates() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dedupe.Duplicates()
+}
+
 func (c *Client) GetQueue() *Queue {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -176,7 +188,8 @@ func (c *Client) Subscribe() (closeFunc func()) {
 		if response.Tick != nil && response.Tick.Data != nil {
 			c.mu.Lock()
 			defer c.mu.Unlock()
-			for idx, t := range response.Tick.Data {
+			trades := c.dedupe.Filter(response.Tick.Data)
+			for idx, t := range trades {
 				if c.queue.Timestamp == 0 {
 					c.queue.Timestamp = t.Timestamp
 				}
@@ -188,7 +201,7 @@ func (c *Client) Subscribe() (closeFunc func()) {
 					c.queue.OutputCash = c.queue.OutputCash.Add(cash)
 					c.queue.InputCoins = c.queue.InputCoins.Add(t.Amount)
 				}
-				if idx == len(response.Tick.Data)-1 {
+				if idx == len(trades)-1 {
 					if t.Timestamp > c.queue.Timestamp+c.duration {
 						queue := c.queue.calculate()
 						for _, handler := range c.handlers {
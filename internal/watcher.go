@@ -2,6 +2,7 @@ package internal
 
 import (
 	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"huobi/flow"
 	"sync"
 )
 
@@ -77,6 +78,8 @@ type WatcherOptions struct {
 	SectionSeconds int64 // 数据分段时差
 	MaxFlows       int   // 最大队列数量，超过该阈值则删除一部分老的数据
 	DelFlows       int   // 队列超过阈值时删除数据量
+	// 用于去重的成交 id 数量, 为 0 时使用 flow.DefaultDedupeSize
+	DedupeSize int
 }
 
 type SectionFlowContainer struct {
@@ -94,6 +97,7 @@ type FlowWatcher struct {
 	maxFlows       int   // 最大队列数量，超过该阈值则删除一部分老的数据
 	delFlows       int   // 队列超过阈值时删除数据量
 	handlers       []*FlowHandler
+	dedupe         *flow.Deduper // 去除重连后重发的成交
 	mu             sync.Mutex
 }
 
@@ -106,6 +110,7 @@ func NewWatcher(options *WatcherOptions) *FlowWatcher {
 		sectionSeconds: options.SectionSeconds,
 		maxFlows:       options.MaxFlows,
 		delFlows:       options.DelFlows,
+		dedupe:         flow.NewDeduper(options.DedupeSize),
 		mu:             sync.Mutex{},
 	}
 }
@@ -146,12 +151,20 @@ func (w *FlowWatcher) GetFlows() []*CashFlow {
 	return append(w.flows, w.flow)
 }
 
+// 丢弃的重复成交数量
+func (w *FlowWatcher) Duplicates() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dedupe.Duplicates()
+}
+
 func (w *FlowWatcher) Subscribe() (closeFunc func()) {
 	return Subscribe(w.symbol, w.clientId, func(response market.SubscribeTradeResponse) {
 		if response.Tick != nil && response.Tick.Data != nil {
 			w.mu.Lock()
 			defer w.mu.Unlock()
-			for idx, t := range response.Tick.Data {
+			trades := w.dedupe.Filter(response.Tick.Data)
+			for idx, t := range trades {
 				t.Timestamp = t.Timestamp / 1000
 				if w.flow.Timestamp == 0 {
 					w.flow.Timestamp = t.Timestamp
@@ -164,7 +177,7 @@ func (w *FlowWatcher) Subscribe() (closeFunc func()) {
 					w.flow.SellCash += cash
 					w.flow.InflowCash -= cash
 				}
-				if idx == len(response.Tick.Data)-1 {
+				if idx == len(trades)-1 {
 					if t.Timestamp >= w.flow.Timestamp+w.sectionSeconds {
 						w.pushFlow()
 						w.flow = &CashFlow{}
@@ -0,0 +1,162 @@
+// Package metrics 定义 Prometheus 监控指标, 指标注册在默认注册表中, 由 /metrics 接口输出
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+const namespace = "huobi"
+
+var (
+	// 收到的逐笔成交数量
+	TradesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trades_received_total",
+		Help:      "Number of trades received from the websocket.",
+	}, []string{"symbol"})
+
+	// 所属数据流已结束, 计入之后数据流的迟到成交数量
+	LateTrades = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "late_trades_total",
+		Help:      "Number of trades that arrived after their bucket was closed.",
+	}, []string{"symbol"})
+
+	// 按成交 id 丢弃的重复成交数量
+	DuplicateTrades = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicate_trades_total",
+		Help:      "Number of trades dropped because their trade id was already seen.",
+	}, []string{"symbol"})
+
+	// 触发补全的次数, reason 为 connect(连接成功, 可能在断线期间缺失成交) 或 trade_id(成交 id 不连续)
+	TradeGaps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trade_gaps_total",
+		Help:      "Number of connects and trade id gaps that triggered a REST backfill.",
+	}, []string{"symbol", "reason"})
+
+	// 通过 REST 接口补全的成交数量
+	BackfilledTrades = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backfilled_trades_total",
+		Help:      "Number of missing trades recovered from the REST API.",
+	}, []string{"symbol"})
+
+	// websocket 重连次数
+	WebsocketReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_reconnects_total",
+		Help:      "Number of websocket reconnections.",
+	}, []string{"symbol"})
+
+	// 单条推送消息的处理耗时
+	MessageProcessing = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "message_processing_seconds",
+		Help:      "Time spent processing one websocket trade message.",
+		Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+	}, []string{"symbol"})
+
+	// 最近一笔成交的时间
+	LastTrade = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_trade_timestamp_seconds",
+		Help:      "Exchange timestamp of the last received trade.",
+	}, []string{"symbol"})
+
+	// 写入数据库的数据量
+	RowsWritten = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_written_total",
+		Help:      "Number of rows written to the database.",
+	}, []string{"symbol", "table"})
+
+	// 批量写入数据库的耗时
+	DBWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_write_seconds",
+		Help:      "Time spent writing one batch to the database.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"symbol", "table"})
+
+	// 数据库写入失败次数
+	DBWriteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_write_errors_total",
+		Help:      "Number of failed database batch writes.",
+	}, []string{"symbol", "table"})
+
+	// 因队列已满或暂存失败而丢弃的数据量
+	RowsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_dropped_total",
+		Help:      "Number of rows dropped because the write queue was full or spooling failed.",
+	}, []string{"symbol", "table"})
+
+	// 各时长数据区间当前的资金净流入
+	WindowInflow = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "window_inflow",
+		Help:      "Current net inflow of the sliding window.",
+	}, []string{"symbol", "window"})
+
+	sinceLastTrade = &tradeClock{last: make(map[string]time.Time)}
+)
+
+func init() {
+	prometheus.MustRegister(
+		TradesReceived,
+		LateTrades,
+		DuplicateTrades,
+		TradeGaps,
+		BackfilledTrades,
+		WebsocketReconnects,
+		MessageProcessing,
+		LastTrade,
+		RowsWritten,
+		DBWriteDuration,
+		DBWriteErrors,
+		RowsDropped,
+		WindowInflow,
+		sinceLastTrade,
+	)
+}
+
+// 记录收到成交的时间, ts 为成交时间(单位: 毫秒)
+func ObserveTrade(symbol string, ts int64) {
+	LastTrade.WithLabelValues(symbol).Set(float64(ts) / 1000)
+	sinceLastTrade.observe(symbol)
+}
+
+// 距最近一次收到成交的时间, 在采集时计算
+type tradeClock struct {
+	last map[string]time.Time
+	mu   sync.Mutex
+}
+
+var sinceLastTradeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "seconds_since_last_trade"),
+	"Seconds since the last trade was received.",
+	[]string{"symbol"}, nil,
+)
+
+func (c *tradeClock) observe(symbol string) {
+	c.mu.Lock()
+	c.last[symbol] = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *tradeClock) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sinceLastTradeDesc
+}
+
+func (c *tradeClock) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for symbol, last := range c.last {
+		ch <- prometheus.MustNewConstMetric(sinceLastTradeDesc, prometheus.GaugeValue, time.Since(last).Seconds(), symbol)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"github.com/morgine/pkg/config"
+	config2 "huobi/config"
+	"huobi/model"
+	"huobi/routes"
+	"strconv"
+	"time"
+)
+
+// 数据库迁移, 对共享数据表(API key 等, 以 shared 标识)及所有订阅的交易对执行:
+//
+//	migrate up                   执行所有未完成的迁移
+//	migrate down [steps]         回滚交易对数据表最近 steps 个迁移, 默认 1 个
+//	migrate down-shared [steps]  回滚共享数据表最近 steps 个迁移, 默认 1 个
+//	migrate status               查看迁移状态
+//	migrate timescale            将交易对数据表转换为 timescaledb 超表, 用于迁移后才安装扩展的数据库
+func runMigrate(configs config.Configs, args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	steps := 1
+	if (action == "down" || action == "down-shared") && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("migrate %s: invalid steps %q", action, args[1])
+		}
+		steps = n
+	}
+	storageConfig, err := config2.NewStorage("storage", configs)
+	if err != nil {
+		return err
+	}
+	subscribes, err := config2.NewSubscribes(configs, storageConfig)
+	if err != nil {
+		return err
+	}
+	if storageConfig.Driver == config2.StorageMemory {
+		return fmt.Errorf("storage driver %s does not support migrations", storageConfig.Driver)
+	}
+	shared, err := routes.NewSharedStorage(storageConfig, configs)
+	if err != nil {
+		return err
+	}
+	defer shared.Close()
+	// 共享数据表不使用超表
+	if action != "down" && action != "timescale" {
+		sharedAction := action
+		if action == "down-shared" {
+			sharedAction = "down"
+		}
+		err = migrate(shared.(model.Schema), "shared", sharedAction, steps)
+		if err != nil || action == "down-shared" {
+			return err
+		}
+	}
+	// 包含通过管理接口新增的交易对, 共享数据表未迁移时仅包含配置文件中的交易对
+	if shared.(model.Schema).CheckSchema() == nil {
+		subscribes, err = routes.EffectiveSubscribes(shared, subscribes, storageConfig, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for _, subscribe := range subscribes {
+		if subscribe.Storage.Driver == config2.StorageMemory {
+			continue
+		}
+		storage, err := routes.NewStorage(subscribe.Storage, subscribe.Symbol, configs)
+		if err != nil {
+			return err
+		}
+		schema, ok := storage.(model.SchemaStorage)
+		if !ok {
+			storage.Close()
+			return fmt.Errorf("storage driver %s does not support migrations", subscribe.Storage.Driver)
+		}
+		err = migrate(schema, subscribe.Symbol, action, steps)
+		storage.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrate(schema model.Schema, symbol, action string, steps int) error {
+	switch action {
+	case "up":
+		applied, err := schema.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("%s: applied %d_%s\n", symbol, m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Printf("%s: already up to date\n", symbol)
+		}
+		return err
+	case "down":
+		reverted, err := schema.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("%s: reverted %d_%s\n", symbol, m.Version, m.Name)
+		}
+		return err
+	case "status":
+		status, err := schema.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			if s.Applied {
+				fmt.Printf("%s: %d_%s applied at %s\n", symbol, s.Version, s.Name, time.Unix(s.AppliedAt, 0).Format(time.RFC3339))
+			} else {
+				fmt.Printf("%s: %d_%s pending\n", symbol, s.Version, s.Name)
+			}
+		}
+		return nil
+	case "timescale":
+		timescale, ok := schema.(model.Timescale)
+		if !ok {
+			return fmt.Errorf("%s: storage does not support timescaledb", symbol)
+		}
+		converted, err := timescale.EnableTimescale()
+		if err != nil {
+			return fmt.Errorf("%s: %w", symbol, err)
+		}
+		if converted {
+			fmt.Printf("%s: converted to hypertables\n", symbol)
+		} else {
+			fmt.Printf("%s: already hypertables\n", symbol)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+// API key 权限范围, admin 包含 read 的所有权限
+const (
+	ScopeRead  = "read"
+	ScopeAdmin = "admin"
+)
+
+// API key, 数据库中仅保存 key 的哈希值
+type APIKey struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"` // key 的前几位, 用于识别 key
+	Hash      string `json:"-"`
+	Scope     string `json:"scope"`
+	RateLimit int    `json:"rate_limit"` // 每秒允许的请求数, 为 0 时使用默认配置
+	CreatedAt int64  `json:"created_at"`
+	RevokedAt int64  `json:"revoked_at"` // 吊销时间, 为 0 时有效
+}
+
+// 是否拥有 scope 权限
+func (k *APIKey) Allow(scope string) bool {
+	return k.Scope == ScopeAdmin || k.Scope == scope
+}
+
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeAdmin
+}
+
+// 生成新的 API key, 返回明文 key 及待保存的记录, 明文 key 仅在此时可见
+func NewAPIKey(name, scope string, rateLimit int) (key string, record *APIKey, err error) {
+	b := make([]byte, 24)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+	key = "hb_" + hex.EncodeToString(b)
+	record = &APIKey{
+		Name:      name,
+		Prefix:    key[:10],
+		Hash:      HashAPIKey(key),
+		Scope:     scope,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now().Unix(),
+	}
+	return key, record, nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (db *SharedDB) CreateAPIKey(key *APIKey) error {
+	return db.db.Create(key).Error
+}
+
+func (db *SharedDB) FindAPIKey(hash string) (*APIKey, error) {
+	key := &APIKey{}
+	err := db.db.Where("hash = ?", hash).Take(key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (db *SharedDB) ListAPIKeys() (keys []*APIKey, err error) {
+	err = db.db.Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (db *SharedDB) RevokeAPIKey(id int) error {
+	tx := db.db.Model(&APIKey{}).Where("id = ? AND revoked_at = 0", id).Update("revoked_at", time.Now().Unix())
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MemorySharedStorage) CreateAPIKey(key *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key.ID = len(m.keys) + 1
+	k := *key
+	m.keys[key.ID] = &k
+	return nil
+}
+
+func (m *MemorySharedStorage) FindAPIKey(hash string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			k := *key
+			return &k, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemorySharedStorage) ListAPIKeys() ([]*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]*APIKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		k := *key
+		keys = append(keys, &k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+func (m *MemorySharedStorage) RevokeAPIKey(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok || key.RevokedAt > 0 {
+		return ErrNotFound
+	}
+	key.RevokedAt = time.Now().Unix()
+	return nil
+}
@@ -1,15 +1,48 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"sync"
+)
 
+// 基于 gorm 的存储, 支持 postgres 及 sqlite, 使用前需执行数据库迁移
 type DB struct {
-	db *gorm.DB
+	schema
+	partitions map[string]bool // 已创建的成交表分区
+	// 成交表是否为原生分区表, 首次写入成交时检查
+	tradesPartitioned *bool
+	mu                sync.Mutex
 }
 
 func NewDB(db *gorm.DB) *DB {
-	err := db.AutoMigrate(&Section{})
+	return &DB{schema: schema{db: db, migrations: migrations}, partitions: make(map[string]bool)}
+}
+
+func (db *DB) Close() error {
+	sqlDB, err := db.db.DB()
 	if err != nil {
-		panic(err)
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// 逐行读取查询结果, 不会一次性加载全部数据
+func each(tx *gorm.DB, newRow func() interface{}, fn func(row interface{}) error) error {
+	rows, err := tx.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := newRow()
+		err = tx.ScanRows(rows, row)
+		if err != nil {
+			return err
+		}
+		err = fn(row)
+		if err != nil {
+			return err
+		}
 	}
-	return &DB{db: db}
+	return rows.Err()
 }
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"sort"
+	"sync"
+)
+
+// 内存存储, 数据不会持久化, 适用于本地开发及测试
+type MemoryStorage struct {
+	sections []*Section
+	trades   []*Trade
+	tradeIds map[int64]bool
+	mu       sync.RWMutex
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{tradeIds: make(map[int64]bool)}
+}
+
+func (m *MemoryStorage) CreateSections(ss []*Section) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range ss {
+		s.ID = len(m.sections) + 1
+		m.sections = append(m.sections, s)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) CreateSectionsInBatches(ss []*Section, batchSize int) error {
+	return m.CreateSections(ss)
+}
+
+func (m *MemoryStorage) CountSection() (total int64, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.sections)), nil
+}
+
+func (m *MemoryStorage) FindSections(limit, offset int) (sections []*Section, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(m.sections) {
+		return nil, nil
+	}
+	end := len(m.sections)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return append(sections, m.sections[offset:end]...), nil
+}
+
+func (m *MemoryStorage) QuerySections(q *SectionQuery) (sections []*Section, err error) {
+	sections = m.filterSections(q)
+	if q.Desc {
+		for i, j := 0, len(sections)-1; i < j; i, j = i+1, j-1 {
+			sections[i], sections[j] = sections[j], sections[i]
+		}
+	}
+	if q.Offset >= len(sections) {
+		return nil, nil
+	}
+	sections = sections[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(sections) {
+		sections = sections[:q.Limit]
+	}
+	return sections, nil
+}
+
+func (m *MemoryStorage) CountSections(q *SectionQuery) (total int64, err error) {
+	return int64(len(m.filterSections(q))), nil
+}
+
+// 按结束时间排序后过滤
+func (m *MemoryStorage) filterSections(q *SectionQuery) (sections []*Section) {
+	m.mu.RLock()
+	all := append([]*Section(nil), m.sections...)
+	m.mu.RUnlock()
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].EndTime < all[j].EndTime
+	})
+	for _, s := range all {
+		if s.EndTime >= q.Start && (q.End <= 0 || s.EndTime < q.End) && !(q.Complete && s.Partial) {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+func (m *MemoryStorage) EachSection(start, end int64, fn func(s *Section) error) error {
+	for _, s := range m.filterSections(&SectionQuery{Start: start, End: end}) {
+		err := fn(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) CreateTrades(ts []*Trade) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range ts {
+		if !m.tradeIds[t.TradeId] {
+			m.tradeIds[t.TradeId] = true
+			m.trades = append(m.trades, t)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) EachTrade(start, end int64, fn func(t *Trade) error) error {
+	m.mu.RLock()
+	trades := append([]*Trade(nil), m.trades...)
+	m.mu.RUnlock()
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Timestamp < trades[j].Timestamp
+	})
+	for _, t := range trades {
+		if t.Timestamp >= start && (end <= 0 || t.Timestamp < end) {
+			err := fn(t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,281 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 数据库结构迁移, 每个版本包含升级及回滚操作, 迁移完成的版本记录在 schema_migrations 表中.
+// 已发布的迁移不可修改, 结构变更需追加新的版本
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// 迁移记录
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt int64
+}
+
+// 迁移状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt int64
+}
+
+var ErrNotMigrated = errors.New("database schema is not migrated")
+
+// 数据库结构
+type Schema interface {
+	// 执行所有未完成的迁移
+	MigrateUp() (applied []*Migration, err error)
+	// 回滚最近 steps 个已完成的迁移
+	MigrateDown(steps int) (reverted []*Migration, err error)
+	MigrationStatus() ([]*MigrationStatus, error)
+	// 检查数据库结构是否为最新版本, 未迁移时返回 ErrNotMigrated
+	CheckSchema() error
+}
+
+// 需要维护数据库结构的存储
+type SchemaStorage interface {
+	Storage
+	Schema
+}
+
+// 基于迁移列表维护数据库结构, 迁移记录保存在带前缀的 schema_migrations 表中
+type schema struct {
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+// 交易对数据表的所有迁移, 按版本号递增排列
+var migrations = []*Migration{
+	{
+		Version: 1,
+		Name:    "create_sections",
+		Up: func(tx *gorm.DB) error {
+			type section struct {
+				ID          int
+				Buy10       int64
+				Sell10      int64
+				Inflow10    int64
+				Buy30       int64
+				Sell30      int64
+				Inflow30    int64
+				Buy60       int64
+				Sell60      int64
+				Inflow60    int64
+				Buy300      int64
+				Sell300     int64
+				Inflow300   int64
+				Buy900      int64
+				Sell900     int64
+				Inflow900   int64
+				Buy3600     int64
+				Sell3600    int64
+				Inflow3600  int64
+				Buy14400    int64
+				Sell14400   int64
+				Inflow14400 int64
+				EndTime     int64
+			}
+			// 兼容之前由 AutoMigrate 创建的数据表
+			table := tableName(tx, "Section")
+			if tx.Migrator().HasTable(table) {
+				return nil
+			}
+			return tx.Table(table).Migrator().CreateTable(&section{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tableName(tx, "Section"))
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_trades",
+		Up:      createTrades,
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tableName(tx, "Trade"))
+		},
+	},
+	{
+		Version: 3,
+		Name:    "timescale_hypertables",
+		Up:      timescaleUp,
+		Down:    timescaleDown,
+	},
+	{
+		Version: 4,
+		Name:    "add_section_buckets",
+		Up: func(tx *gorm.DB) error {
+			type section struct {
+				BucketBuy    int64 `gorm:"not null;default:0"`
+				BucketSell   int64 `gorm:"not null;default:0"`
+				BucketInflow int64 `gorm:"not null;default:0"`
+				Partial      bool  `gorm:"not null;default:false"`
+			}
+			migrator := tx.Table(tableName(tx, "Section")).Migrator()
+			for _, column := range sectionBucketColumns {
+				err := migrator.AddColumn(&section{}, column)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			migrator := tx.Table(tableName(tx, "Section")).Migrator()
+			for _, column := range sectionBucketColumns {
+				err := migrator.DropColumn(&Section{}, column)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_section_backfilled",
+		Up: func(tx *gorm.DB) error {
+			type section struct {
+				Backfilled bool `gorm:"not null;default:false"`
+			}
+			return tx.Table(tableName(tx, "Section")).Migrator().AddColumn(&section{}, "Backfilled")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Table(tableName(tx, "Section")).Migrator().DropColumn(&Section{}, "Backfilled")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_section_has_bucket",
+		Up: func(tx *gorm.DB) error {
+			// 已有的数据区间无法确定是否保存了数据流, 均为 false
+			type section struct {
+				HasBucket bool `gorm:"not null;default:false"`
+			}
+			return tx.Table(tableName(tx, "Section")).Migrator().AddColumn(&section{}, "HasBucket")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Table(tableName(tx, "Section")).Migrator().DropColumn(&Section{}, "HasBucket")
+		},
+	},
+}
+
+var sectionBucketColumns = []string{"BucketBuy", "BucketSell", "BucketInflow", "Partial"}
+
+// 带前缀的数据表名称
+func tableName(tx *gorm.DB, model string) string {
+	return tx.NamingStrategy.TableName(model)
+}
+
+func (db *schema) MigrateUp() (applied []*Migration, err error) {
+	if !db.db.Migrator().HasTable(&SchemaMigration{}) {
+		err = db.db.Migrator().CreateTable(&SchemaMigration{})
+		if err != nil {
+			return nil, err
+		}
+	}
+	done, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range db.migrations {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		err = db.db.Transaction(func(tx *gorm.DB) error {
+			err := m.Up(tx)
+			if err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().Unix()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migrate up %d_%s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+func (db *schema) MigrateDown(steps int) (reverted []*Migration, err error) {
+	done, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(db.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := db.migrations[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		err = db.db.Transaction(func(tx *gorm.DB) error {
+			err := m.Down(tx)
+			if err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migrate down %d_%s: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+func (db *schema) MigrationStatus() ([]*MigrationStatus, error) {
+	done, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var status []*MigrationStatus
+	for _, m := range db.migrations {
+		s := &MigrationStatus{Version: m.Version, Name: m.Name}
+		if applied, ok := done[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = applied.AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+func (db *schema) CheckSchema() error {
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		if !s.Applied {
+			return fmt.Errorf("%w: version %d_%s is pending", ErrNotMigrated, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// 已完成的迁移, 迁移记录表不存在时视为没有完成任何迁移
+func (db *schema) appliedMigrations() (map[int64]*SchemaMigration, error) {
+	done := make(map[int64]*SchemaMigration)
+	if !db.db.Migrator().HasTable(&SchemaMigration{}) {
+		return done, nil
+	}
+	var records []*SchemaMigration
+	err := db.db.Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
@@ -1,5 +1,7 @@
 package model
 
+import "gorm.io/gorm"
+
 type Section struct {
 	ID          int
 	Buy10       int64
@@ -24,18 +26,204 @@ type Section struct {
 	Sell14400   int64
 	Inflow14400 int64
 	EndTime     int64
+	// 结束的数据流, 用于重启后恢复数据区间
+	BucketBuy    int64
+	BucketSell   int64
+	BucketInflow int64
+	// 存在未完整覆盖的数据区间, 如启动后数据不足 14400 秒时的 Inflow14400
+	Partial bool
+	// 结束的数据流包含断线后通过 REST 接口补全的成交
+	Backfilled bool
+	// 保存了数据流, 为 false 时 BucketBuy 等为 0 而不是空数据流, 不能用于恢复数据区间. 只在内部使用, 不包含在 SectionColumns 中
+	HasBucket bool
+}
+
+// 数据区间字段, 与数据表列名一致
+var SectionColumns = []string{
+	"id", "end_time",
+	"buy10", "sell10", "inflow10",
+	"buy30", "sell30", "inflow30",
+	"buy60", "sell60", "inflow60",
+	"buy300", "sell300", "inflow300",
+	"buy900", "sell900", "inflow900",
+	"buy3600", "sell3600", "inflow3600",
+	"buy14400", "sell14400", "inflow14400",
+	"bucket_buy", "bucket_sell", "bucket_inflow",
+	"partial", "backfilled",
+}
+
+// 按列名获取字段值, partial 及 backfilled 为 bool, 其余为 int64, 列名不存在时 ok 为 false
+func (s *Section) Column(name string) (value interface{}, ok bool) {
+	switch name {
+	case "partial":
+		return s.Partial, true
+	case "backfilled":
+		return s.Backfilled, true
+	case "bucket_buy":
+		return s.BucketBuy, true
+	case "bucket_sell":
+		return s.BucketSell, true
+	case "bucket_inflow":
+		return s.BucketInflow, true
+	case "id":
+		return int64(s.ID), true
+	case "end_time":
+		return s.EndTime, true
+	case "buy10":
+		return s.Buy10, true
+	case "sell10":
+		return s.Sell10, true
+	case "inflow10":
+		return s.Inflow10, true
+	case "buy30":
+		return s.Buy30, true
+	case "sell30":
+		return s.Sell30, true
+	case "inflow30":
+		return s.Inflow30, true
+	case "buy60":
+		return s.Buy60, true
+	case "sell60":
+		return s.Sell60, true
+	case "inflow60":
+		return s.Inflow60, true
+	case "buy300":
+		return s.Buy300, true
+	case "sell300":
+		return s.Sell300, true
+	case "inflow300":
+		return s.Inflow300, true
+	case "buy900":
+		return s.Buy900, true
+	case "sell900":
+		return s.Sell900, true
+	case "inflow900":
+		return s.Inflow900, true
+	case "buy3600":
+		return s.Buy3600, true
+	case "sell3600":
+		return s.Sell3600, true
+	case "inflow3600":
+		return s.Inflow3600, true
+	case "buy14400":
+		return s.Buy14400, true
+	case "sell14400":
+		return s.Sell14400, true
+	case "inflow14400":
+		return s.Inflow14400, true
+	}
+	return 0, false
+}
+
+// 设置指定时长的数据区间, 时长不是数据表中的列时 ok 为 false
+func (s *Section) SetWindow(duration, buy, sell, inflow int64) (ok bool) {
+	switch duration {
+	case 10:
+		s.Buy10, s.Sell10, s.Inflow10 = buy, sell, inflow
+	case 30:
+		s.Buy30, s.Sell30, s.Inflow30 = buy, sell, inflow
+	case 60:
+		s.Buy60, s.Sell60, s.Inflow60 = buy, sell, inflow
+	case 300:
+		s.Buy300, s.Sell300, s.Inflow300 = buy, sell, inflow
+	case 900:
+		s.Buy900, s.Sell900, s.Inflow900 = buy, sell, inflow
+	case 3600:
+		s.Buy3600, s.Sell3600, s.Inflow3600 = buy, sell, inflow
+	case 14400:
+		s.Buy14400, s.Sell14400, s.Inflow14400 = buy, sell, inflow
+	default:
+		return false
+	}
+	return true
+}
+
+// 数据区间查询条件
+type SectionQuery struct {
+	Start    int64 // 结束时间不小于 Start(单位: 秒)
+	End      int64 // 结束时间小于 End(单位: 秒), 为 0 时不限制
+	Limit    int   // 为 0 时不限制数量
+	Offset   int
+	Desc     bool     // 按结束时间倒序排列
+	Columns  []string // 只查询指定的列, 为空时查询全部
+	Complete bool     // 只查询数据完整的数据区间, 即 partial 为 false
 }
 
-func (db *DB) CreateSection(s *Section) {
-	db.db.Create(s)
+func (db *DB) CreateSection(s *Section) error {
+	return db.db.Create(s).Error
 }
 
-func (db *DB) CountSection() (total int64) {
-	db.db.Model(&Section{}).Count(&total)
+// 批量写入, 生成单条多行 INSERT 语句
+func (db *DB) CreateSections(ss []*Section) error {
+	return db.db.Create(ss).Error
+}
+
+// 在同一事务中分批写入, 任意一批失败则全部回滚
+func (db *DB) CreateSectionsInBatches(ss []*Section, batchSize int) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(ss); start += batchSize {
+			end := start + batchSize
+			if end > len(ss) {
+				end = len(ss)
+			}
+			err := tx.Create(ss[start:end]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) CountSection() (total int64, err error) {
+	err = db.db.Model(&Section{}).Count(&total).Error
+	return
+}
+
+func (db *DB) FindSections(limit, offset int) (sections []*Section, err error) {
+	err = db.db.Limit(limit).Offset(offset).Find(&sections).Error
 	return
 }
 
-func (db *DB) FindSections(limit, offset int) (sections []*Section) {
-	db.db.Limit(limit).Offset(offset).Find(&sections)
+func (db *DB) QuerySections(q *SectionQuery) (sections []*Section, err error) {
+	tx := db.whereSections(q).Limit(q.Limit).Offset(q.Offset)
+	if len(q.Columns) > 0 {
+		tx = tx.Select(q.Columns)
+	}
+	if q.Desc {
+		tx = tx.Order("end_time DESC, id DESC")
+	} else {
+		tx = tx.Order("end_time, id")
+	}
+	err = tx.Find(&sections).Error
 	return
 }
+
+func (db *DB) CountSections(q *SectionQuery) (total int64, err error) {
+	err = db.whereSections(q).Count(&total).Error
+	return
+}
+
+func (db *DB) whereSections(q *SectionQuery) *gorm.DB {
+	tx := db.db.Model(&Section{})
+	if q.Start > 0 {
+		tx = tx.Where("end_time >= ?", q.Start)
+	}
+	if q.End > 0 {
+		tx = tx.Where("end_time < ?", q.End)
+	}
+	if q.Complete {
+		tx = tx.Where("partial = ?", false)
+	}
+	return tx
+}
+
+func (db *DB) EachSection(start, end int64, fn func(s *Section) error) error {
+	tx := db.db.Model(&Section{}).Where("end_time >= ?", start)
+	if end > 0 {
+		tx = tx.Where("end_time < ?", end)
+	}
+	return each(tx.Order("end_time, id"), func() interface{} { return &Section{} }, func(row interface{}) error {
+		return fn(row.(*Section))
+	})
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"sync"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+// 不区分交易对的共享数据存储
+type SharedStorage interface {
+	CreateAPIKey(key *APIKey) error
+	// 根据 key 的哈希值查找, 不存在时返回 nil
+	FindAPIKey(hash string) (*APIKey, error)
+	ListAPIKeys() ([]*APIKey, error)
+	// 吊销 key, 不存在时返回 ErrNotFound
+	RevokeAPIKey(id int) error
+	// 运行时修改的订阅状态
+	ListSubscriptionStates() ([]*SubscriptionState, error)
+	// 保存订阅状态, 已存在时覆盖
+	SaveSubscriptionState(state *SubscriptionState) error
+	Close() error
+}
+
+// 基于 gorm 的共享数据存储, 使用前需执行数据库迁移
+type SharedDB struct {
+	schema
+}
+
+func NewSharedDB(db *gorm.DB) *SharedDB {
+	return &SharedDB{schema: schema{db: db, migrations: sharedMigrations}}
+}
+
+func (db *SharedDB) Close() error {
+	sqlDB, err := db.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// 内存共享数据存储, 数据不会持久化
+type MemorySharedStorage struct {
+	keys   map[int]*APIKey
+	states map[string]*SubscriptionState
+	mu     sync.RWMutex
+}
+
+func NewMemorySharedStorage() *MemorySharedStorage {
+	return &MemorySharedStorage{keys: make(map[int]*APIKey), states: make(map[string]*SubscriptionState)}
+}
+
+func (m *MemorySharedStorage) Close() error {
+	return nil
+}
+
+// 共享数据表的所有迁移, 按版本号递增排列
+var sharedMigrations = []*Migration{
+	{
+		Version: 1,
+		Name:    "create_api_keys",
+		Up: func(tx *gorm.DB) error {
+			type apiKey struct {
+				ID        int
+				Name      string `gorm:"size:64"`
+				Prefix    string `gorm:"size:16"`
+				Hash      string `gorm:"size:64;uniqueIndex"`
+				Scope     string `gorm:"size:16"`
+				RateLimit int
+				CreatedAt int64
+				RevokedAt int64
+			}
+			return tx.Table(tableName(tx, "APIKey")).Migrator().CreateTable(&apiKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tableName(tx, "APIKey"))
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_subscription_states",
+		Up: func(tx *gorm.DB) error {
+			type subscriptionState struct {
+				Symbol    string `gorm:"primaryKey;size:32"`
+				ClientId  string `gorm:"size:64"`
+				Paused    bool
+				Removed   bool
+				UpdatedAt int64
+			}
+			return tx.Table(tableName(tx, "SubscriptionState")).Migrator().CreateTable(&subscriptionState{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tableName(tx, "SubscriptionState"))
+		},
+	},
+}
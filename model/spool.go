@@ -0,0 +1,155 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// 本地暂存文件, 数据库写入失败的数据以 JSON 行的形式追加到文件末尾,
+// 数据库恢复后按原顺序重新写入并清空文件
+type Spool struct {
+	filename string
+	newRow   func() interface{} // 创建用于解码的空数据
+	pending  int64              // 文件中等待重新写入的数据量
+}
+
+// 数据区间暂存文件
+func OpenSectionSpool(filename string) (*Spool, error) {
+	return openSpool(filename, func() interface{} { return &Section{} })
+}
+
+// 逐笔成交暂存文件
+func OpenTradeSpool(filename string) (*Spool, error) {
+	return openSpool(filename, func() interface{} { return &Trade{} })
+}
+
+func openSpool(filename string, newRow func() interface{}) (*Spool, error) {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spool{filename: filename, newRow: newRow}
+	err = s.truncateBrokenTail()
+	if err != nil {
+		return nil, err
+	}
+	// 统计上次运行遗留的数据
+	rows, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.pending = int64(len(rows))
+	if s.pending > 0 {
+		applogger.Info("spool %s has %d rows waiting for replay", filename, s.pending)
+	}
+	return s, nil
+}
+
+func (s *Spool) Pending() int64 {
+	return atomic.LoadInt64(&s.pending)
+}
+
+// 追加数据, 写入后同步到磁盘
+func (s *Spool) Append(rows []interface{}) error {
+	f, err := os.OpenFile(s.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		// 主键由数据库重新生成
+		if section, ok := row.(*Section); ok {
+			section.ID = 0
+		}
+		err = encoder.Encode(row)
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.pending, int64(len(rows)))
+	return nil
+}
+
+// 将暂存数据交给 write 重新写入, 写入成功后清空文件
+func (s *Spool) Replay(write func(rows []interface{}) error) error {
+	rows, err := s.read()
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		err = write(rows)
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Truncate(s.filename, 0)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	atomic.StoreInt64(&s.pending, 0)
+	return nil
+}
+
+// 进程异常退出时最后一行可能不完整, 截断至最后一个完整行, 避免之后追加的数据接在不完整的行后
+func (s *Spool) truncateBrokenTail() error {
+	data, err := ioutil.ReadFile(s.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	size := bytes.LastIndexByte(data, '\n') + 1
+	applogger.Warn("spool %s has a broken last line, %d bytes discarded", s.filename, len(data)-size)
+	return os.Truncate(s.filename, int64(size))
+}
+
+// 按行解码暂存数据, 无法解码的行跳过
+func (s *Spool) read() (rows []interface{}, err error) {
+	f, err := os.Open(s.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			row := s.newRow()
+			if e := json.Unmarshal(line, row); e != nil {
+				applogger.Warn("spool %s line %d is broken: %s", s.filename, n, e)
+			} else {
+				rows = append(rows, row)
+			}
+		}
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
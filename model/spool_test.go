@@ -0,0 +1,218 @@
+package model
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sectionRows(endTimes ...int64) []interface{} {
+	rows := make([]interface{}, 0, len(endTimes))
+	for _, endTime := range endTimes {
+		rows = append(rows, &Section{ID: int(endTime), EndTime: endTime})
+	}
+	return rows
+}
+
+func endTimes(rows []interface{}) []int64 {
+	var times []int64
+	for _, row := range rows {
+		times = append(times, row.(*Section).EndTime)
+	}
+	return times
+}
+
+func equalInt64s(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpoolReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "spool", "sections.jsonl")
+	spool, err := OpenSectionSpool(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Pending() != 0 {
+		t.Fatalf("Pending() = %d, want 0", spool.Pending())
+	}
+	for _, rows := range [][]interface{}{sectionRows(10, 20), sectionRows(30)} {
+		err = spool.Append(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spool.Pending() != 3 {
+		t.Fatalf("Pending() = %d, want 3", spool.Pending())
+	}
+
+	// 写入失败时保留暂存数据
+	err = spool.Replay(func(rows []interface{}) error { return errors.New("db down") })
+	if err == nil || spool.Pending() != 3 {
+		t.Fatalf("Replay() error = %v, Pending() = %d, want error and 3 rows kept", err, spool.Pending())
+	}
+
+	var replayed []interface{}
+	err = spool.Replay(func(rows []interface{}) error {
+		replayed = rows
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := endTimes(replayed); !equalInt64s(got, []int64{10, 20, 30}) {
+		t.Fatalf("replayed %v, want [10 20 30] in order", got)
+	}
+	// 主键由数据库重新生成
+	if replayed[0].(*Section).ID != 0 {
+		t.Fatalf("replayed ID = %d, want 0", replayed[0].(*Section).ID)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 || spool.Pending() != 0 {
+		t.Fatalf("spool size = %d, Pending() = %d, want truncated", info.Size(), spool.Pending())
+	}
+
+	// 暂存文件为空时不调用 write
+	err = spool.Replay(func(rows []interface{}) error {
+		t.Fatalf("write called with %d rows", len(rows))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSpool(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sections.jsonl")
+	// 进程异常退出时最后一行不完整
+	err := ioutil.WriteFile(filename, []byte(`{"EndTime":10}`+"\n"+`{"EndTime":20}`+"\n"+`{"EndTi`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool, err := OpenSectionSpool(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Pending() != 2 {
+		t.Fatalf("Pending() = %d, want 2 rows left by last run", spool.Pending())
+	}
+	// 之后追加的数据不能接在不完整的行后
+	err = spool.Append(sectionRows(30, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Pending() != 4 {
+		t.Fatalf("Pending() = %d, want 4", spool.Pending())
+	}
+	var replayed []interface{}
+	err = spool.Replay(func(rows []interface{}) error {
+		replayed = rows
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := endTimes(replayed); !equalInt64s(got, []int64{10, 20, 30, 40}) {
+		t.Fatalf("replayed %v, want [10 20 30 40]", got)
+	}
+}
+
+func TestSpoolSkipsBrokenLines(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sections.jsonl")
+	err := ioutil.WriteFile(filename, []byte(`{"EndTime":10}`+"\n"+`{"EndTi`+"\n"+`{"EndTime":30}`+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool, err := OpenSectionSpool(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replayed []interface{}
+	err = spool.Replay(func(rows []interface{}) error {
+		replayed = rows
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := endTimes(replayed); !equalInt64s(got, []int64{10, 30}) {
+		t.Fatalf("replayed %v, want [10 30]", got)
+	}
+}
+
+func TestSectionWriterReplay(t *testing.T) {
+	storage := NewMemoryStorage()
+	spool, err := OpenSectionSpool(filepath.Join(t.TempDir(), "sections.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spool.Append(sectionRows(10, 20, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 上次运行时暂存数据已部分写入, 清空暂存文件前进程退出
+	err = storage.CreateSections([]*Section{{EndTime: 10}, {EndTime: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewSectionWriter(storage, &WriterOptions{
+		Symbol:        "testusdt",
+		Table:         "sections",
+		QueueSize:     10,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		Spool:         spool,
+	})
+	writer.Write(&Section{EndTime: 40})
+	writer.Close()
+
+	var got []int64
+	err = storage.EachSection(0, 0, func(s *Section) error {
+		got = append(got, s.EndTime)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalInt64s(got, []int64{10, 20, 30, 40}) {
+		t.Fatalf("sections %v, want [10 20 30 40] without duplicates", got)
+	}
+	if spool.Pending() != 0 {
+		t.Fatalf("Pending() = %d, want 0", spool.Pending())
+	}
+}
+
+func TestWriterWriteAfterClose(t *testing.T) {
+	spool, err := OpenSectionSpool(filepath.Join(t.TempDir(), "sections.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewSectionWriter(NewMemoryStorage(), &WriterOptions{
+		Symbol:        "testusdt",
+		Table:         "sections",
+		QueueSize:     1,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		Spool:         spool,
+	})
+	writer.Close()
+	if writer.Write(&Section{EndTime: 10}) {
+		t.Fatal("Write() after Close() = true, want false")
+	}
+	if dropped := writer.Stats().Dropped; dropped != 0 {
+		t.Fatalf("Dropped = %d, want 0 for writes after close", dropped)
+	}
+}
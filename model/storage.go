@@ -0,0 +1,22 @@
+package model
+
+// 数据存储接口, 每个交易对使用独立的存储
+type Storage interface {
+	// 批量写入
+	CreateSections(ss []*Section) error
+	// 在同一事务中分批写入, 任意一批失败则全部回滚
+	CreateSectionsInBatches(ss []*Section, batchSize int) error
+	CountSection() (total int64, err error)
+	FindSections(limit, offset int) (sections []*Section, err error)
+	// 按条件查询数据区间
+	QuerySections(q *SectionQuery) (sections []*Section, err error)
+	CountSections(q *SectionQuery) (total int64, err error)
+	// 按结束时间顺序遍历 [start, end) 内的数据区间, 时间单位为秒, end 为 0 时不限制结束时间
+	EachSection(start, end int64, fn func(s *Section) error) error
+	// 写入成交, 已存在的成交将被忽略
+	CreateTrades(ts []*Trade) error
+	// 按成交时间顺序遍历 [start, end) 内的成交, 时间单位为毫秒, end 为 0 时不限制结束时间
+	EachTrade(start, end int64, fn func(t *Trade) error) error
+	// 释放存储占用的资源
+	Close() error
+}
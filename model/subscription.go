@@ -0,0 +1,47 @@
+package model
+
+import (
+	"gorm.io/gorm/clause"
+	"sort"
+)
+
+// 通过管理接口修改的订阅状态, 覆盖配置文件中的订阅, 重启后仍然有效.
+// 配置文件中不存在的交易对为运行时新增的订阅
+type SubscriptionState struct {
+	Symbol    string `gorm:"primaryKey" json:"symbol"`
+	ClientId  string `json:"client_id"`
+	Paused    bool   `json:"paused"`  // 已暂停, 保留数据区间但不接收成交
+	Removed   bool   `json:"removed"` // 已删除, 配置文件中的同名订阅不再生效
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (db *SharedDB) ListSubscriptionStates() (states []*SubscriptionState, err error) {
+	err = db.db.Order("symbol").Find(&states).Error
+	return states, err
+}
+
+func (db *SharedDB) SaveSubscriptionState(state *SubscriptionState) error {
+	return db.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(state).Error
+}
+
+func (m *MemorySharedStorage) ListSubscriptionStates() ([]*SubscriptionState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	states := make([]*SubscriptionState, 0, len(m.states))
+	for _, state := range m.states {
+		s := *state
+		states = append(states, &s)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Symbol < states[j].Symbol
+	})
+	return states, nil
+}
+
+func (m *MemorySharedStorage) SaveSubscriptionState(state *SubscriptionState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := *state
+	m.states[state.Symbol] = &s
+	return nil
+}
@@ -0,0 +1,245 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// timescaledb 超表配置, 数据区间时间单位为秒, 成交时间单位为毫秒
+const (
+	sectionChunkInterval = 7 * 86400         // 数据区间每个分块 7 天
+	sectionCompressAfter = 30 * 86400        // 数据区间 30 天后压缩
+	tradeChunkInterval   = 86400 * 1000      // 成交每个分块 1 天
+	tradeCompressAfter   = 30 * 86400 * 1000 // 成交 30 天后压缩
+)
+
+var ErrNoTimescale = errors.New("timescaledb extension is not installed")
+
+// 支持转换为 timescaledb 超表的存储
+type Timescale interface {
+	// 将数据表转换为超表, 已转换时不做修改并返回 false
+	EnableTimescale() (converted bool, err error)
+}
+
+// 数据库是否安装了 timescaledb 扩展
+func hasTimescale(tx *gorm.DB) (bool, error) {
+	if tx.Dialector.Name() != "postgres" {
+		return false, nil
+	}
+	var count int64
+	err := tx.Raw("SELECT count(*) FROM pg_extension WHERE extname = 'timescaledb'").Row().Scan(&count)
+	return count > 0, err
+}
+
+func isHypertable(tx *gorm.DB, table string) (bool, error) {
+	var count int64
+	err := tx.Raw("SELECT count(*) FROM timescaledb_information.hypertables WHERE hypertable_name = ?", table).Row().Scan(&count)
+	return count > 0, err
+}
+
+// 数据库安装了 timescaledb 扩展时转换为超表. 未安装时保留普通表, 迁移版本仍然记录,
+// 之后安装扩展需执行 migrate timescale 转换
+func timescaleUp(tx *gorm.DB) error {
+	ok, err := hasTimescale(tx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if tx.Dialector.Name() == "postgres" {
+			applogger.Warn("%s, tables %s and %s are kept as plain tables, run \"migrate timescale\" after installing it",
+				ErrNoTimescale, tableName(tx, "Section"), tableName(tx, "Trade"))
+		}
+		return nil
+	}
+	return createHypertables(tx)
+}
+
+// 将数据表转换为超表, 用于迁移后才安装 timescaledb 扩展的数据库
+func (db *DB) EnableTimescale() (converted bool, err error) {
+	err = db.CheckSchema()
+	if err != nil {
+		return false, err
+	}
+	err = db.db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() != "postgres" {
+			return fmt.Errorf("%s does not support timescaledb", tx.Dialector.Name())
+		}
+		ok, err := hasTimescale(tx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w, run \"CREATE EXTENSION timescaledb\" first", ErrNoTimescale)
+		}
+		ok, err = isHypertable(tx, tableName(tx, "Section"))
+		if err != nil || ok {
+			return err
+		}
+		converted = true
+		return createHypertables(tx)
+	})
+	if err != nil {
+		return false, err
+	}
+	return converted, nil
+}
+
+// 将数据区间表及成交表转换为以时间分块的超表, 并添加压缩策略及汇总视图
+func createHypertables(tx *gorm.DB) error {
+	sections := tableName(tx, "Section")
+	trades := tableName(tx, "Trade")
+	var statements []string
+	// 超表的唯一索引必须包含分块时间字段
+	statements = append(statements,
+		fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", quote(tx, sections), quote(tx, sections+"_pkey")),
+		fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id, end_time)", quote(tx, sections)),
+		fmt.Sprintf("SELECT create_hypertable(%s, 'end_time', chunk_time_interval => %d, migrate_data => true)", literal(sections), sectionChunkInterval),
+	)
+	statements = append(statements, integerNow(tx, sections, "extract(epoch FROM now())::bigint")...)
+	statements = append(statements,
+		fmt.Sprintf("ALTER TABLE %s SET (timescaledb.compress, timescaledb.compress_orderby = 'end_time DESC, id')", quote(tx, sections)),
+		fmt.Sprintf("SELECT add_compression_policy(%s, %d)", literal(sections), sectionCompressAfter),
+		// 每小时汇总: 小时末的 1 小时窗口数据及 5 分钟窗口的最大净流入与净流出
+		fmt.Sprintf(`CREATE MATERIALIZED VIEW %s WITH (timescaledb.continuous) AS
+SELECT time_bucket(3600, end_time) AS bucket,
+	count(*) AS sections,
+	last(buy3600, end_time) AS buy3600,
+	last(sell3600, end_time) AS sell3600,
+	last(inflow3600, end_time) AS inflow3600,
+	max(inflow300) AS max_inflow300,
+	min(inflow300) AS min_inflow300
+FROM %s GROUP BY bucket WITH NO DATA`, quote(tx, sections+"_1h"), quote(tx, sections)),
+		fmt.Sprintf("SELECT add_continuous_aggregate_policy(%s, start_offset => %d, end_offset => %d, schedule_interval => INTERVAL '1 hour')",
+			literal(sections+"_1h"), 3*86400, 3600),
+	)
+
+	// 原生分区表无法转换为超表, 重新创建成交表后复制数据
+	statements = append(statements,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quote(tx, trades), quote(tx, trades+"_partitioned")),
+		fmt.Sprintf(`CREATE TABLE %s (
+	trade_id bigint NOT NULL,
+	price numeric NOT NULL,
+	amount numeric NOT NULL,
+	direction varchar(4) NOT NULL,
+	"timestamp" bigint NOT NULL,
+	PRIMARY KEY (trade_id, "timestamp")
+)`, quote(tx, trades)),
+		fmt.Sprintf(`SELECT create_hypertable(%s, 'timestamp', chunk_time_interval => %d)`, literal(trades), tradeChunkInterval),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", quote(tx, trades), quote(tx, trades+"_partitioned")),
+		fmt.Sprintf("DROP TABLE %s", quote(tx, trades+"_partitioned")),
+	)
+	statements = append(statements, integerNow(tx, trades, "(extract(epoch FROM now()) * 1000)::bigint")...)
+	statements = append(statements,
+		fmt.Sprintf(`ALTER TABLE %s SET (timescaledb.compress, timescaledb.compress_orderby = '"timestamp" DESC, trade_id')`, quote(tx, trades)),
+		fmt.Sprintf("SELECT add_compression_policy(%s, %d)", literal(trades), tradeCompressAfter),
+		// 每分钟 K 线及主动买入、卖出成交额
+		fmt.Sprintf(`CREATE MATERIALIZED VIEW %s WITH (timescaledb.continuous) AS
+SELECT time_bucket(60000, "timestamp") AS bucket,
+	first(price, "timestamp") AS open,
+	max(price) AS high,
+	min(price) AS low,
+	last(price, "timestamp") AS close,
+	sum(amount) AS amount,
+	sum(CASE WHEN direction = 'buy' THEN price * amount ELSE 0 END) AS buy,
+	sum(CASE WHEN direction = 'sell' THEN price * amount ELSE 0 END) AS sell,
+	count(*) AS trades
+FROM %s GROUP BY bucket WITH NO DATA`, quote(tx, trades+"_1m"), quote(tx, trades)),
+		fmt.Sprintf("SELECT add_continuous_aggregate_policy(%s, start_offset => %d, end_offset => %d, schedule_interval => INTERVAL '1 minute')",
+			literal(trades+"_1m"), 86400*1000, 60000),
+	)
+	return execAll(tx, statements)
+}
+
+// 将超表还原为普通表及原生分区表, 数据复制到新表后删除超表
+func timescaleDown(tx *gorm.DB) error {
+	ok, err := hasTimescale(tx)
+	if err != nil || !ok {
+		return err
+	}
+	sections := tableName(tx, "Section")
+	trades := tableName(tx, "Trade")
+	ok, err = isHypertable(tx, sections)
+	if err != nil || !ok {
+		return err
+	}
+	var sequence string
+	err = tx.Raw("SELECT pg_get_serial_sequence(?, 'id')", sections).Row().Scan(&sequence)
+	if err != nil {
+		return err
+	}
+	err = execAll(tx, []string{
+		fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s", quote(tx, sections+"_1h")),
+		fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s", quote(tx, trades+"_1m")),
+		// 删除超表时保留主键序列
+		fmt.Sprintf("ALTER SEQUENCE %s OWNED BY NONE", sequence),
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS)", quote(tx, sections+"_plain"), quote(tx, sections)),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", quote(tx, sections+"_plain"), quote(tx, sections)),
+		fmt.Sprintf("DROP TABLE %s", quote(tx, sections)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quote(tx, sections+"_plain"), quote(tx, sections)),
+		fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id)", quote(tx, sections)),
+		fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.id", sequence, quote(tx, sections)),
+		fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", quote(tx, sections+"_now")),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quote(tx, trades), quote(tx, trades+"_hypertable")),
+	})
+	if err != nil {
+		return err
+	}
+	err = createTrades(tx)
+	if err != nil {
+		return err
+	}
+	// 为已有数据创建分区
+	var min, max *int64
+	err = tx.Raw(fmt.Sprintf(`SELECT min("timestamp"), max("timestamp") FROM %s`, quote(tx, trades+"_hypertable"))).Row().Scan(&min, &max)
+	if err != nil {
+		return err
+	}
+	if min != nil && max != nil {
+		for ts := *min; ; {
+			err = createTradePartition(tx, trades, ts)
+			if err != nil {
+				return err
+			}
+			_, end := tradePartitionRange(ts)
+			ts = toMillis(end)
+			if ts > *max {
+				break
+			}
+		}
+	}
+	return execAll(tx, []string{
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", quote(tx, trades), quote(tx, trades+"_hypertable")),
+		fmt.Sprintf("DROP TABLE %s", quote(tx, trades+"_hypertable")),
+		fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", quote(tx, trades+"_now")),
+	})
+}
+
+// 整数时间的超表需要指定获取当前时间的函数, 压缩策略及汇总视图刷新策略依赖该函数
+func integerNow(tx *gorm.DB, table, expr string) []string {
+	return []string{
+		fmt.Sprintf("CREATE OR REPLACE FUNCTION %s() RETURNS bigint LANGUAGE SQL STABLE AS $$ SELECT %s $$", quote(tx, table+"_now"), expr),
+		fmt.Sprintf("SELECT set_integer_now_func(%s, %s)", literal(table), literal(table+"_now")),
+	}
+}
+
+func execAll(tx *gorm.DB, statements []string) error {
+	for _, statement := range statements {
+		err := tx.Exec(statement).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func quote(tx *gorm.DB, name string) string {
+	return tx.Statement.Quote(name)
+}
+
+// 字符串常量, 用于函数参数中的表名
+func literal(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormschema "gorm.io/gorm/schema"
+)
+
+func TestEnableTimescale(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		NamingStrategy: gormschema.NamingStrategy{TablePrefix: "testusdt_"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := NewDB(gdb)
+	defer db.Close()
+	_, err = db.EnableTimescale()
+	if !errors.Is(err, ErrNotMigrated) {
+		t.Fatalf("EnableTimescale() error = %v, want %v", err, ErrNotMigrated)
+	}
+	// 未安装扩展时保留普通表, 其后的迁移继续执行
+	_, err = db.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.CheckSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := db.EnableTimescale()
+	if converted || err == nil || !strings.Contains(err.Error(), "does not support timescaledb") {
+		t.Fatalf("EnableTimescale() = %v, %v, want unsupported error", converted, err)
+	}
+}
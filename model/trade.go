@@ -0,0 +1,126 @@
+package model
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// 逐笔成交, 以成交 ID 去重
+type Trade struct {
+	TradeId   int64           `gorm:"primaryKey;autoIncrement:false"`
+	Price     decimal.Decimal `gorm:"type:numeric;not null"`
+	Amount    decimal.Decimal `gorm:"type:numeric;not null"`
+	Direction string          `gorm:"size:4;not null"`                // buy, sell
+	Timestamp int64           `gorm:"primaryKey;autoIncrement:false"` // 成交时间(单位: 毫秒)
+}
+
+// 写入成交, 已存在的成交将被忽略
+func (db *DB) CreateTrades(ts []*Trade) error {
+	err := db.ensureTradePartitions(ts)
+	if err != nil {
+		return err
+	}
+	return db.db.Clauses(clause.OnConflict{DoNothing: true}).Create(ts).Error
+}
+
+func (db *DB) EachTrade(start, end int64, fn func(t *Trade) error) error {
+	tx := db.db.Model(&Trade{}).Where(`"timestamp" >= ?`, start)
+	if end > 0 {
+		tx = tx.Where(`"timestamp" < ?`, end)
+	}
+	return each(tx.Order(`"timestamp", trade_id`), func() interface{} { return &Trade{} }, func(row interface{}) error {
+		return fn(row.(*Trade))
+	})
+}
+
+// postgres 中成交表按月分区, 写入前创建成交时间所在月份的分区
+func (db *DB) ensureTradePartitions(ts []*Trade) error {
+	if db.db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	table := tableName(db.db, "Trade")
+	// 转换为 timescaledb 超表后由 timescaledb 管理分区
+	if db.tradesPartitioned == nil {
+		partitioned, err := isPartitioned(db.db, table)
+		if err != nil {
+			return err
+		}
+		db.tradesPartitioned = &partitioned
+	}
+	if !*db.tradesPartitioned {
+		return nil
+	}
+	for _, t := range ts {
+		start, _ := tradePartitionRange(t.Timestamp)
+		partition := tradePartitionName(table, start)
+		if db.partitions[partition] {
+			continue
+		}
+		err := createTradePartition(db.db, table, t.Timestamp)
+		if err != nil {
+			return err
+		}
+		db.partitions[partition] = true
+	}
+	return nil
+}
+
+// 创建成交时间所在月份的分区
+func createTradePartition(tx *gorm.DB, table string, timestamp int64) error {
+	start, end := tradePartitionRange(timestamp)
+	return tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%d) TO (%d)",
+		tx.Statement.Quote(tradePartitionName(table, start)), tx.Statement.Quote(table), toMillis(start), toMillis(end))).Error
+}
+
+func tradePartitionName(table string, start time.Time) string {
+	return fmt.Sprintf("%s_%s", table, start.Format("200601"))
+}
+
+// 数据表是否为 postgres 原生分区表
+func isPartitioned(tx *gorm.DB, table string) (bool, error) {
+	var kind string
+	err := tx.Raw("SELECT relkind::text FROM pg_class WHERE oid = to_regclass(?)", table).Row().Scan(&kind)
+	if err != nil {
+		return false, err
+	}
+	return kind == "p", nil
+}
+
+// 成交时间所在月份的起止时间
+func tradePartitionRange(timestamp int64) (start, end time.Time) {
+	t := time.Unix(0, timestamp*int64(time.Millisecond)).UTC()
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// 成交表, postgres 中创建为按成交时间分区的表, 分区键必须包含在主键中
+func createTrades(tx *gorm.DB) error {
+	table := tableName(tx, "Trade")
+	if tx.Dialector.Name() == "postgres" {
+		return tx.Exec(fmt.Sprintf(`CREATE TABLE %s (
+	trade_id bigint NOT NULL,
+	price numeric NOT NULL,
+	amount numeric NOT NULL,
+	direction varchar(4) NOT NULL,
+	"timestamp" bigint NOT NULL,
+	PRIMARY KEY (trade_id, "timestamp")
+) PARTITION BY RANGE ("timestamp")`, tx.Statement.Quote(table))).Error
+	}
+	type trade struct {
+		TradeId   int64           `gorm:"primaryKey;autoIncrement:false"`
+		Price     decimal.Decimal `gorm:"type:numeric;not null"`
+		Amount    decimal.Decimal `gorm:"type:numeric;not null"`
+		Direction string          `gorm:"size:4;not null"`
+		Timestamp int64           `gorm:"primaryKey;autoIncrement:false"`
+	}
+	return tx.Table(table).Migrator().CreateTable(&trade{})
+}
@@ -0,0 +1,297 @@
+package model
+
+import (
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"huobi/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type WriterOptions struct {
+	Symbol        string        // 交易对, 用于日志及监控指标
+	Table         string        // 数据表, 用于日志及监控指标
+	QueueSize     int           // 队列容量, 队列已满时新数据将被丢弃
+	BatchSize     int           // 单次批量写入的最大数据量
+	FlushInterval time.Duration // 定时写入间隔
+	Spool         *Spool        // 写入失败时暂存数据的本地文件
+}
+
+// 写入失败后重试暂存数据的最小间隔
+const spoolRetryInterval = 10 * time.Second
+
+// 异步批量写入器, 数据先进入有界队列, 由后台协程按数量或时间间隔批量写入数据库,
+// 写入操作不会阻塞调用方
+type Writer struct {
+	name      string
+	symbol    string
+	table     string
+	queue     chan interface{}
+	insert    func(rows []interface{}) error // 批量写入
+	replayAll func(rows []interface{}) error // 重新写入暂存数据, 需保证全部成功或全部失败
+	batchSize int
+	interval  time.Duration
+	spool     *Spool
+	written   int64
+	dropped   int64
+	failed    int64
+	lastFail  time.Time
+	health    WriterHealth
+	healthMu  sync.Mutex
+	closed    bool
+	mu        sync.RWMutex
+	done      chan struct{}
+}
+
+// 写入器状态
+type WriterStats struct {
+	Queued   int   `json:"queued"`   // 队列中等待写入的数据量
+	Capacity int   `json:"capacity"` // 队列容量
+	Written  int64 `json:"written"`  // 已写入的数据量
+	Dropped  int64 `json:"dropped"`  // 因队列已满或暂存失败而丢弃的数据量
+	Failed   int64 `json:"failed"`   // 数据库写入失败次数
+	Spooled  int64 `json:"spooled"`  // 暂存文件中等待重新写入的数据量
+}
+
+// 最近一次数据库写入的结果, 时间单位为秒
+type WriterHealth struct {
+	OK            bool   `json:"ok"` // 最近一次写入是否成功, 尚未写入时为 true
+	LastSuccessAt int64  `json:"last_success_at"`
+	LastFailureAt int64  `json:"last_failure_at"`
+	LastError     string `json:"last_error,omitempty"` // 最近一次写入失败的原因
+}
+
+// 数据区间写入器, 写入数据类型为 *Section. 暂存数据写入成功后、清空暂存文件前进程退出时暂存数据会再次写入,
+// 因此重新写入时跳过结束时间已存在的数据区间
+func NewSectionWriter(storage Storage, options *WriterOptions) *Writer {
+	return newWriter(options, func(rows []interface{}) error {
+		return storage.CreateSections(toSections(rows))
+	}, func(rows []interface{}) error {
+		sections, err := UnwrittenSections(storage, toSections(rows))
+		if err != nil {
+			return err
+		}
+		return storage.CreateSectionsInBatches(sections, options.BatchSize)
+	})
+}
+
+// 去除结束时间已存在于存储中的数据区间
+func UnwrittenSections(storage Storage, sections []*Section) ([]*Section, error) {
+	if len(sections) == 0 {
+		return sections, nil
+	}
+	start, end := sections[0].EndTime, sections[0].EndTime
+	for _, s := range sections {
+		if s.EndTime < start {
+			start = s.EndTime
+		}
+		if s.EndTime > end {
+			end = s.EndTime
+		}
+	}
+	written := make(map[int64]bool)
+	err := storage.EachSection(start, end+1, func(s *Section) error {
+		written[s.EndTime] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	unwritten := make([]*Section, 0, len(sections))
+	for _, s := range sections {
+		if !written[s.EndTime] {
+			unwritten = append(unwritten, s)
+		}
+	}
+	return unwritten, nil
+}
+
+// 逐笔成交写入器, 写入数据类型为 *Trade, 重复的成交会被忽略, 因此暂存数据可直接分批重新写入
+func NewTradeWriter(storage Storage, options *WriterOptions) *Writer {
+	insert := func(rows []interface{}) error {
+		return storage.CreateTrades(toTrades(rows))
+	}
+	return newWriter(options, insert, insert)
+}
+
+func newWriter(options *WriterOptions, insert, replayAll func(rows []interface{}) error) *Writer {
+	w := &Writer{
+		name:      options.Symbol + "_" + options.Table,
+		symbol:    options.Symbol,
+		table:     options.Table,
+		queue:     make(chan interface{}, options.QueueSize),
+		insert:    insert,
+		replayAll: replayAll,
+		batchSize: options.BatchSize,
+		interval:  options.FlushInterval,
+		spool:     options.Spool,
+		health:    WriterHealth{OK: true},
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// 将数据加入写入队列, 队列已满时丢弃数据并返回 false.
+// 写入器已关闭时同样返回 false, 关闭过程中的写入不计入丢弃数据
+func (w *Writer) Write(row interface{}) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+	select {
+	case w.queue <- row:
+		return true
+	default:
+	}
+	metrics.RowsDropped.WithLabelValues(w.symbol, w.table).Inc()
+	if dropped := atomic.AddInt64(&w.dropped, 1); dropped == 1 || dropped%1000 == 0 {
+		applogger.Warn("writer %s queue is full, %d rows dropped", w.name, dropped)
+	}
+	return false
+}
+
+func (w *Writer) Stats() *WriterStats {
+	return &WriterStats{
+		Queued:   len(w.queue),
+		Capacity: cap(w.queue),
+		Written:  atomic.LoadInt64(&w.written),
+		Dropped:  atomic.LoadInt64(&w.dropped),
+		Failed:   atomic.LoadInt64(&w.failed),
+		Spooled:  w.spool.Pending(),
+	}
+}
+
+func (w *Writer) Health() WriterHealth {
+	w.healthMu.Lock()
+	defer w.healthMu.Unlock()
+	return w.health
+}
+
+// 关闭写入器, 等待队列中剩余数据全部写入后返回
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.done
+	stats := w.Stats()
+	applogger.Info("writer %s closed, %d rows written, %d rows dropped, %d rows spooled", w.name, stats.Written, stats.Dropped, stats.Spooled)
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	batch := make([]interface{}, 0, w.batchSize)
+	for {
+		select {
+		case row, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, row)
+			if len(batch) >= w.batchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		}
+	}
+}
+
+// 批量写入数据, 返回清空后的缓冲区. 暂存文件中有数据时先重新写入暂存数据,
+// 暂存数据未能写入时新数据也进入暂存文件, 以保持写入顺序
+func (w *Writer) flush(batch []interface{}) []interface{} {
+	if w.spool.Pending() > 0 && !w.replay() {
+		w.append(batch)
+	} else if len(batch) > 0 {
+		err := w.observe(w.insert, batch)
+		if err != nil {
+			w.fail(err)
+			w.append(batch)
+		} else {
+			w.addWritten(int64(len(batch)))
+		}
+	}
+	return batch[:0]
+}
+
+// 重新写入暂存数据, 返回暂存数据是否已全部写入
+func (w *Writer) replay() bool {
+	if time.Since(w.lastFail) < spoolRetryInterval {
+		return false
+	}
+	pending := w.spool.Pending()
+	err := w.spool.Replay(func(rows []interface{}) error {
+		return w.observe(w.replayAll, rows)
+	})
+	if err != nil {
+		w.fail(err)
+		return false
+	}
+	w.addWritten(pending)
+	applogger.Info("writer %s replayed %d spooled rows", w.name, pending)
+	return true
+}
+
+func (w *Writer) append(batch []interface{}) {
+	if len(batch) == 0 {
+		return
+	}
+	err := w.spool.Append(batch)
+	if err != nil {
+		atomic.AddInt64(&w.dropped, int64(len(batch)))
+		metrics.RowsDropped.WithLabelValues(w.symbol, w.table).Add(float64(len(batch)))
+		applogger.Error("writer %s failed to spool %d rows: %s", w.name, len(batch), err)
+	}
+}
+
+// 写入数据并记录耗时
+func (w *Writer) observe(write func(rows []interface{}) error, rows []interface{}) error {
+	start := time.Now()
+	err := write(rows)
+	metrics.DBWriteDuration.WithLabelValues(w.symbol, w.table).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (w *Writer) addWritten(n int64) {
+	w.healthMu.Lock()
+	w.health.OK = true
+	w.health.LastSuccessAt = time.Now().Unix()
+	w.healthMu.Unlock()
+	atomic.AddInt64(&w.written, n)
+	metrics.RowsWritten.WithLabelValues(w.symbol, w.table).Add(float64(n))
+}
+
+func (w *Writer) fail(err error) {
+	w.lastFail = time.Now()
+	w.healthMu.Lock()
+	w.health.OK = false
+	w.health.LastFailureAt = w.lastFail.Unix()
+	w.health.LastError = err.Error()
+	w.healthMu.Unlock()
+	atomic.AddInt64(&w.failed, 1)
+	metrics.DBWriteErrors.WithLabelValues(w.symbol, w.table).Inc()
+	applogger.Error("writer %s failed to write rows: %s", w.name, err)
+}
+
+func toSections(rows []interface{}) []*Section {
+	sections := make([]*Section, len(rows))
+	for i, row := range rows {
+		sections[i] = row.(*Section)
+	}
+	return sections
+}
+
+func toTrades(rows []interface{}) []*Trade {
+	trades := make([]*Trade, len(rows))
+	for i, row := range rows {
+		trades[i] = row.(*Trade)
+	}
+	return trades
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"github.com/morgine/pkg/config"
+	config2 "huobi/config"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// 检查配置文件是否修改的间隔
+const configPollInterval = 5 * time.Second
+
+// 收到 SIGHUP 或配置文件的修改时间、大小变化时调用 reload
+func watchConfig(file string, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	last, _ := os.Stat(file)
+	for {
+		select {
+		case <-hup:
+			applogger.Info("SIGHUP received, reloading %s", file)
+		case <-ticker.C:
+			info, err := os.Stat(file)
+			if err != nil || (last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size()) {
+				continue
+			}
+			applogger.Info("%s changed, reloading", file)
+		}
+		last, _ = os.Stat(file)
+		reload()
+	}
+}
+
+// 重新加载配置文件并再次应用环境变量及 -set 参数, 配置文件无法解析或订阅配置有误时保留当前配置
+func reloadConfig(file string, sets []string, reload func(configs config.Configs) error) {
+	configs, err := config2.Load(file, sets)
+	if err != nil {
+		applogger.Error("reload config failed: %s", err)
+		return
+	}
+	logConfig, err := config2.NewLog("log", configs)
+	if err != nil {
+		applogger.Error("reload config failed: %s", err)
+		return
+	}
+	applogger.SetLevel(logConfig.ZapLevel())
+	err = reload(configs)
+	if err != nil {
+		applogger.Error("reload config failed: %s", err)
+		return
+	}
+	applogger.Info("config reloaded")
+}
@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"huobi/config"
+	"huobi/model"
+	"time"
+)
+
+// 错误码
+const (
+	codeConflict = "conflict"
+	codeConfig   = "config_error"
+)
+
+// 注册管理接口, 需要 admin 权限. 未开启认证时不注册, 避免任意网页跨域调用
+func registerAdmin(group *gin.RouterGroup, r *registry) {
+	if r.auth == nil {
+		return
+	}
+	admin := group.Group("", r.auth.require(model.ScopeAdmin))
+	admin.POST("/symbols", r.addSymbol)
+	admin.DELETE("/symbols/:symbol", r.removeSymbol)
+	admin.POST("/symbols/:symbol/pause", r.pauseSymbol)
+	admin.POST("/symbols/:symbol/resume", r.resumeSymbol)
+}
+
+// 生效的订阅
+type subscribeState struct {
+	config.Subscribe
+	Paused bool
+}
+
+// 合并配置文件中的订阅及通过管理接口修改的订阅状态: 已删除的订阅不再生效,
+// 配置文件中不存在的订阅为运行时新增的订阅, 使用默认配置及全局存储配置 storage、行情连接配置 market.
+// 配置文件中未启用的订阅仍包含在结果中
+func mergeSubscribes(configured []config.Subscribe, states []*model.SubscriptionState, storage *config.Storage, market *config.Market) []*subscribeState {
+	overrides := make(map[string]*model.SubscriptionState, len(states))
+	for _, state := range states {
+		overrides[state.Symbol] = state
+	}
+	var merged []*subscribeState
+	for _, subscribe := range configured {
+		state, ok := overrides[subscribe.Symbol]
+		delete(overrides, subscribe.Symbol)
+		if ok && state.Removed {
+			continue
+		}
+		merged = append(merged, &subscribeState{Subscribe: subscribe, Paused: ok && state.Paused})
+	}
+	for _, state := range states {
+		if _, ok := overrides[state.Symbol]; ok && !state.Removed {
+			merged = append(merged, &subscribeState{
+				Subscribe: config.NewSubscribe(state.Symbol, state.ClientId, storage, market),
+				Paused:    state.Paused,
+			})
+		}
+	}
+	return merged
+}
+
+// 生效的订阅, 包含已暂停及配置文件中未启用的订阅
+func EffectiveSubscribes(shared model.SharedStorage, configured []config.Subscribe, storage *config.Storage, market *config.Market) ([]config.Subscribe, error) {
+	states, err := shared.ListSubscriptionStates()
+	if err != nil {
+		return nil, err
+	}
+	var subscribes []config.Subscribe
+	for _, state := range mergeSubscribes(configured, states, storage, market) {
+		subscribes = append(subscribes, state.Subscribe)
+	}
+	return subscribes, nil
+}
+
+func (s *subscription) state() *model.SubscriptionState {
+	return &model.SubscriptionState{
+		Symbol:    s.Symbol,
+		ClientId:  s.ClientId,
+		Paused:    s.isPaused(),
+		UpdatedAt: time.Now().Unix(),
+	}
+}
+
+type addSymbolParams struct {
+	Symbol   string `json:"symbol" binding:"required"`
+	ClientId string `json:"client_id"` // 默认与交易对相同, 配置文件中存在该交易对时使用配置文件中的 client id
+	Paused   bool   `json:"paused"`    // 添加后暂不订阅
+}
+
+// 新增订阅, 创建数据表、写入器及订阅客户端后立即开始订阅
+func (r *registry) addSymbol(ctx *gin.Context) {
+	ps := &addSymbolParams{}
+	err := ctx.ShouldBindJSON(ps)
+	if err != nil {
+		fail(ctx, 400, codeInvalidParams, "%s", err)
+		return
+	}
+	if !config.ValidSymbol(ps.Symbol) {
+		fail(ctx, 400, codeInvalidParams, "invalid symbol %q", ps.Symbol)
+		return
+	}
+	r.adminMu.Lock()
+	defer r.adminMu.Unlock()
+	if r.get(ps.Symbol) != nil {
+		fail(ctx, 409, codeConflict, "symbol %s is already subscribed", ps.Symbol)
+		return
+	}
+	if r.disabled[ps.Symbol] {
+		fail(ctx, 409, codeConflict, "symbol %s is disabled in config", ps.Symbol)
+		return
+	}
+	subscribe, configured, err := r.configuredSubscribe(ps.Symbol, ps.ClientId)
+	if err != nil {
+		fail(ctx, 500, codeConfig, "%s", err)
+		return
+	}
+	if configured && ps.ClientId != "" && ps.ClientId != subscribe.ClientId {
+		fail(ctx, 409, codeConflict, "symbol %s is configured with client_id %s", ps.Symbol, subscribe.ClientId)
+		return
+	}
+	s, err := r.newSubscription(subscribe, true)
+	if err != nil {
+		fail(ctx, 500, codeStorage, "%s", err)
+		return
+	}
+	s.paused = ps.Paused
+	err = r.shared.SaveSubscriptionState(s.state())
+	if err != nil {
+		s.close()
+		fail(ctx, 500, codeStorage, "%s", err)
+		return
+	}
+	r.add(s)
+	s.start()
+	ctx.JSON(201, &Response{Data: s.info()})
+}
+
+// 新增订阅使用的配置. 配置文件中存在该交易对(如删除后重新添加)时使用配置文件中的订阅, 与 mergeSubscribes 一致,
+// 避免重新加载配置时认为订阅已修改而重启订阅. 不存在时使用默认配置, clientId 为空时与交易对相同
+func (r *registry) configuredSubscribe(symbol, clientId string) (subscribe config.Subscribe, configured bool, err error) {
+	subscribes, err := config.NewSubscribes(r.configs, r.storageConfig)
+	if err != nil {
+		return subscribe, false, err
+	}
+	for _, subscribe := range subscribes {
+		if subscribe.Symbol == symbol {
+			return subscribe, true, nil
+		}
+	}
+	return config.NewSubscribe(symbol, clientId, r.storageConfig, r.marketConfig), false, nil
+}
+
+// 删除订阅, 写入剩余数据后释放存储, 已保存的数据不会删除
+func (r *registry) removeSymbol(ctx *gin.Context) {
+	r.adminMu.Lock()
+	defer r.adminMu.Unlock()
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	state := s.state()
+	state.Removed = true
+	err := r.shared.SaveSubscriptionState(state)
+	if err != nil {
+		fail(ctx, 500, codeStorage, "%s", err)
+		return
+	}
+	r.remove(s)
+	s.close()
+	success(ctx, s.info())
+}
+
+func (r *registry) pauseSymbol(ctx *gin.Context) {
+	r.setPaused(ctx, true)
+}
+
+func (r *registry) resumeSymbol(ctx *gin.Context) {
+	r.setPaused(ctx, false)
+}
+
+// 暂停或恢复订阅, 暂停期间保留内存中的数据区间
+func (r *registry) setPaused(ctx *gin.Context, paused bool) {
+	r.adminMu.Lock()
+	defer r.adminMu.Unlock()
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	state := s.state()
+	state.Paused = paused
+	err := r.shared.SaveSubscriptionState(state)
+	if err != nil {
+		fail(ctx, 500, codeStorage, "%s", err)
+		return
+	}
+	s.setPaused(paused)
+	success(ctx, s.info())
+}
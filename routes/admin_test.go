@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+
+	config2 "github.com/morgine/pkg/config"
+	"huobi/config"
+)
+
+func TestConfiguredSubscribe(t *testing.T) {
+	configs, err := config2.UnmarshalMemory([]byte("[[subscriptions]]\nsymbol = \"btcusdt\"\nclient_id = \"1602\"\nbucket_size = 30\nwindows = [60, 300]\nstorage = \"sqlite\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := &config.Storage{Driver: "memory"}
+	r := &registry{configs: configs, storageConfig: storage, marketConfig: config.DefaultMarket()}
+	subscribes, err := config.NewSubscribes(configs, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		symbol         string
+		clientId       string
+		want           config.Subscribe
+		wantConfigured bool
+	}{
+		// 与重新加载配置时合并的订阅相同
+		{name: "configured", symbol: "btcusdt", want: subscribes[0], wantConfigured: true},
+		{name: "default", symbol: "ethusdt", want: config.NewSubscribe("ethusdt", "", storage, r.marketConfig)},
+		{name: "default with client id", symbol: "ethusdt", clientId: "1603", want: config.NewSubscribe("ethusdt", "1603", storage, r.marketConfig)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, configured, err := r.configuredSubscribe(test.symbol, test.clientId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if configured != test.wantConfigured || !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("configuredSubscribe() = %+v, %v, want %+v, %v", got, configured, test.want, test.wantConfigured)
+			}
+		})
+	}
+	if subscribes[0].BucketSize != 30 || subscribes[0].ClientId != "1602" || subscribes[0].Storage.Driver != "sqlite" {
+		t.Fatalf("configured subscribe = %+v, want bucket_size, client_id and storage from config", subscribes[0])
+	}
+}
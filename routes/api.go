@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"huobi/model"
+	"strings"
+)
+
+// 注册 v1 版本接口
+func registerAPI(api *gin.RouterGroup, r *registry) {
+	api.GET("/symbols", r.listSymbols)
+	api.GET("/symbols/:symbol", r.getSymbol)
+	api.GET("/symbols/:symbol/sections", r.findSections)
+	api.GET("/symbols/:symbol/sections/count", r.countSections)
+	api.GET("/symbols/:symbol/export", r.export)
+	api.GET("/symbols/:symbol/writer-stats", r.writerStats)
+	api.GET("/symbols/:symbol/live", r.live)
+}
+
+// 交易对信息
+type Symbol struct {
+	Symbol      string  `json:"symbol"`
+	ClientId    string  `json:"client_id"`
+	Paused      bool    `json:"paused"`
+	BucketSize  int64   `json:"bucket_size"`  // 数据流时长(单位: 秒)
+	GracePeriod int64   `json:"grace_period"` // 迟到成交的宽限时间(单位: 毫秒)
+	Windows     []int64 `json:"windows"`      // 数据区间时长(单位: 秒)
+}
+
+func (s *subscription) info() *Symbol {
+	return &Symbol{
+		Symbol:      s.Symbol,
+		ClientId:    s.ClientId,
+		Paused:      s.isPaused(),
+		BucketSize:  s.BucketSize,
+		GracePeriod: s.GracePeriod,
+		Windows:     s.currentWindows(),
+	}
+}
+
+func (r *registry) listSymbols(ctx *gin.Context) {
+	subscriptions := r.list()
+	symbols := make([]*Symbol, 0, len(subscriptions))
+	for _, s := range subscriptions {
+		symbols = append(symbols, s.info())
+	}
+	success(ctx, symbols)
+}
+
+func (r *registry) getSymbol(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	success(ctx, s.info())
+}
+
+// 数据区间查询参数
+type sectionParams struct {
+	Start    int64  `form:"start" binding:"min=0"`          // 结束时间不小于 start(单位: 秒)
+	End      int64  `form:"end" binding:"min=0"`            // 结束时间小于 end(单位: 秒)
+	Limit    int    `form:"limit" binding:"min=0,max=1000"` // 默认 100, 最大 1000
+	Offset   int    `form:"offset" binding:"min=0"`
+	Order    string `form:"order" binding:"omitempty,oneof=asc desc"` // 按结束时间排序, 默认 asc
+	Fields   string `form:"fields"`                                   // 返回的字段, 以逗号分隔, 默认返回全部字段
+	Complete bool   `form:"complete"`                                 // 为 true 时只返回数据完整(partial 为 false)的数据区间
+}
+
+// 解析并校验查询参数, 校验失败时返回 400
+func bindSectionQuery(ctx *gin.Context) (*model.SectionQuery, bool) {
+	ps := &sectionParams{}
+	err := ctx.ShouldBindQuery(ps)
+	if err != nil {
+		fail(ctx, 400, codeInvalidParams, "%s", err)
+		return nil, false
+	}
+	if ps.End > 0 && ps.End <= ps.Start {
+		fail(ctx, 400, codeInvalidParams, "end must be greater than start")
+		return nil, false
+	}
+	if ps.Limit == 0 {
+		ps.Limit = 100
+	}
+	q := &model.SectionQuery{
+		Start:    ps.Start,
+		End:      ps.End,
+		Limit:    ps.Limit,
+		Offset:   ps.Offset,
+		Desc:     ps.Order == "desc",
+		Complete: ps.Complete,
+	}
+	if ps.Fields != "" {
+		for _, field := range strings.Split(ps.Fields, ",") {
+			field = strings.TrimSpace(field)
+			if !isSectionColumn(field) {
+				fail(ctx, 400, codeInvalidParams, "unknown field %q", field)
+				return nil, false
+			}
+			q.Columns = append(q.Columns, field)
+		}
+	}
+	return q, true
+}
+
+func isSectionColumn(name string) bool {
+	for _, column := range model.SectionColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *registry) findSections(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	q, ok := bindSectionQuery(ctx)
+	if !ok {
+		return
+	}
+	sections, err := s.storage.QuerySections(q)
+	if err != nil {
+		fail(ctx, 500, codeStorage, "%s", err)
+		return
+	}
+	total, err := s.storage.CountSections(q)
+	if err != nil {
+		fail(ctx, 500, codeStorage, "%s", err)
+		return
+	}
+	columns := q.Columns
+	if len(columns) == 0 {
+		columns = model.SectionColumns
+	}
+	data := make([]map[string]interface{}, len(sections))
+	for i, section := range sections {
+		row := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			row[column], _ = section.Column(column)
+		}
+		data[i] = row
+	}
+	successList(ctx, data, &Meta{Total: total, Limit: q.Limit, Offset: q.Offset})
+}
+
+func (r *registry) countSections(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	q, ok := bindSectionQuery(ctx)
+	if !ok {
+		return
+	}
+	total, err := s.storage.CountSections(q)
+	if err != nil {
+		fail(ctx, 500, codeStorage, "%s", err)
+		return
+	}
+	success(ctx, total)
+}
+
+func (r *registry) writerStats(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	success(ctx, s.writerStats())
+}
+
+func (r *registry) writerStatsLegacy(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(200, s.writerStats())
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"huobi/config"
+	"huobi/model"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 错误码
+const (
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+	codeRateLimited  = "rate_limited"
+)
+
+const apiKeyContextKey = "api_key"
+
+// API key 认证及限流
+type authenticator struct {
+	cfg      *config.Auth
+	keys     model.SharedStorage
+	limiters map[int]*limiter // 按 key 限流
+	mu       sync.Mutex
+}
+
+func newAuthenticator(cfg *config.Auth, keys model.SharedStorage) *authenticator {
+	return &authenticator{cfg: cfg, keys: keys, limiters: make(map[int]*limiter)}
+}
+
+// 要求请求携带拥有 scope 权限的 API key, 未开启认证时不做检查.
+// key 通过 Authorization: Bearer <key> 或 X-API-Key 请求头传递
+func (a *authenticator) require(scope string) gin.HandlerFunc {
+	return a.check(scope, false)
+}
+
+// 与 require 相同, 同时接受 api_key 查询参数. 只用于推送接口, 浏览器的 EventSource 及 WebSocket 无法设置请求头
+func (a *authenticator) requireStream(scope string) gin.HandlerFunc {
+	return a.check(scope, true)
+}
+
+func (a *authenticator) check(scope string, allowQuery bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if a == nil {
+			return
+		}
+		raw := requestAPIKey(ctx, allowQuery)
+		if raw == "" {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			fail(ctx, 401, codeUnauthorized, "missing api key")
+			return
+		}
+		key, err := a.keys.FindAPIKey(model.HashAPIKey(raw))
+		if err != nil {
+			fail(ctx, 500, codeStorage, "%s", err)
+			return
+		}
+		if key == nil || key.RevokedAt > 0 {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			fail(ctx, 401, codeUnauthorized, "invalid api key")
+			return
+		}
+		if !key.Allow(scope) {
+			fail(ctx, 403, codeForbidden, "api key %s does not have %s scope", key.Prefix, scope)
+			return
+		}
+		if wait := a.limiter(key).take(); wait > 0 {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			fail(ctx, 429, codeRateLimited, "rate limit exceeded, retry after %s", wait.Round(time.Millisecond))
+			return
+		}
+		ctx.Set(apiKeyContextKey, key)
+	}
+}
+
+func requestAPIKey(ctx *gin.Context, allowQuery bool) string {
+	if s := ctx.GetHeader("Authorization"); strings.HasPrefix(s, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(s, "Bearer "))
+	}
+	if s := ctx.GetHeader("X-API-Key"); s != "" {
+		return s
+	}
+	if allowQuery {
+		return ctx.Query("api_key")
+	}
+	return ""
+}
+
+// 获取 key 的限流器, key 单独设置的速率优先于默认配置
+func (a *authenticator) limiter(key *model.APIKey) *limiter {
+	rate := float64(a.cfg.RateLimit)
+	if key.RateLimit > 0 {
+		rate = float64(key.RateLimit)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l, ok := a.limiters[key.ID]
+	if !ok || l.rate != rate {
+		l = newLimiter(rate, a.cfg.Burst)
+		a.limiters[key.ID] = l
+	}
+	return l
+}
+
+// 令牌桶限流器
+type limiter struct {
+	rate   float64 // 每秒生成的令牌数, 为 0 时不限制
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// 获取一个令牌, 令牌不足时返回需要等待的时间
+func (l *limiter) take() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	}
+	l.tokens--
+	return 0
+}
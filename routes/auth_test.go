@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"huobi/config"
+	"huobi/model"
+)
+
+func newAuthEngine(t *testing.T) (engine *gin.Engine, key string) {
+	gin.SetMode(gin.TestMode)
+	keys := model.NewMemorySharedStorage()
+	key, record, err := model.NewAPIKey("test", model.ScopeRead, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = keys.CreateAPIKey(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := newAuthenticator(&config.Auth{Enabled: true}, keys)
+	engine = gin.New()
+	ok := func(ctx *gin.Context) { ctx.String(200, "ok") }
+	engine.GET("/api/v1/symbols", auth.require(model.ScopeRead), ok)
+	engine.GET("/api/v1/sections/stream", auth.requireStream(model.ScopeRead), ok)
+	engine.POST("/api/v1/symbols", auth.require(model.ScopeAdmin), ok)
+	return engine, key
+}
+
+func TestAuthenticator(t *testing.T) {
+	engine, key := newAuthEngine(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{name: "bearer", path: "/api/v1/symbols", header: map[string]string{"Authorization": "Bearer " + key}, want: 200},
+		{name: "header", path: "/api/v1/symbols", header: map[string]string{"X-API-Key": key}, want: 200},
+		{name: "missing", path: "/api/v1/symbols", want: 401},
+		{name: "invalid", path: "/api/v1/symbols", header: map[string]string{"X-API-Key": "hb_invalid"}, want: 401},
+		{name: "query on regular endpoint", path: "/api/v1/symbols?api_key=" + key, want: 401},
+		{name: "query on stream endpoint", path: "/api/v1/sections/stream?api_key=" + key, want: 200},
+		{name: "header on stream endpoint", path: "/api/v1/sections/stream", header: map[string]string{"X-API-Key": key}, want: 200},
+		{name: "scope", method: "POST", path: "/api/v1/symbols", header: map[string]string{"X-API-Key": key}, want: 403},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, test.path, nil)
+			for name, value := range test.header {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != test.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestRedactAPIKey(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/symbols", "/api/v1/symbols"},
+		{"/api/v1/sections/stream?api_key=hb_secret", "/api/v1/sections/stream?api_key=REDACTED"},
+		{"/api/v1/sections/stream?symbols=btcusdt&api_key=hb_secret&columns=id", "/api/v1/sections/stream?symbols=btcusdt&api_key=REDACTED&columns=id"},
+		{"/api/v1/sections/ws?api%5Fkey=hb_secret", "/api/v1/sections/ws?api%5Fkey=REDACTED"},
+		{"/api/v1/sections/ws?api_key", "/api/v1/sections/ws?api_key=REDACTED"},
+		{"/api/v1/sections/ws?my_api_key=1", "/api/v1/sections/ws?my_api_key=1"},
+	}
+	for _, test := range tests {
+		if got := redactAPIKey(test.path); got != test.want {
+			t.Fatalf("redactAPIKey(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestLoggerRedactsAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// Logger 创建时使用 gin.DefaultWriter 输出日志
+	var out bytes.Buffer
+	writer := gin.DefaultWriter
+	gin.DefaultWriter = &out
+	defer func() { gin.DefaultWriter = writer }()
+	engine := gin.New()
+	engine.Use(Logger())
+	engine.GET("/api/v1/sections/stream", func(ctx *gin.Context) { ctx.String(200, "ok") })
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/sections/stream?symbols=btcusdt&api_key=hb_secret", nil))
+	if strings.Contains(out.String(), "hb_secret") || !strings.Contains(out.String(), "symbols=btcusdt&api_key=REDACTED") {
+		t.Fatalf("log output should redact api key: %s", out.String())
+	}
+}
+
+func TestRegisterAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := model.NewMemorySharedStorage()
+	tests := []struct {
+		name string
+		auth *authenticator
+		want int
+	}{
+		// 未开启认证时管理接口不存在
+		{name: "auth disabled", want: 404},
+		{name: "auth enabled", auth: newAuthenticator(&config.Auth{Enabled: true}, keys), want: 401},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := gin.New()
+			registerAdmin(engine.Group("/api/v1"), &registry{auth: test.auth})
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodPost, "/api/v1/symbols", strings.NewReader(`{"symbol":"btcusdt"}`)),
+				httptest.NewRequest(http.MethodDelete, "/api/v1/symbols/btcusdt", nil),
+			} {
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, req)
+				if w.Code != test.want {
+					t.Fatalf("%s %s status = %d, want %d", req.Method, req.URL.Path, w.Code, test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net"
+	"time"
+)
+
+type connContextKey struct{}
+
+// 将连接保存到请求的 context 中, 用作 http.Server.ConnContext, 长时间输出数据的接口据此延长写入超时
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// 将连接的写入超时延长至 timeout 之后. 服务端的 WriteTimeout 从读取请求后开始计算,
+// 推送及导出接口在每次写入前调用, 客户端停止接收时仍会超时断开
+func extendWriteDeadline(ctx *gin.Context, timeout time.Duration) {
+	if conn, ok := ctx.Request.Context().Value(connContextKey{}).(net.Conn); ok {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+}
+
+// 每次写入前延长写入超时的 io.Writer
+type deadlineWriter struct {
+	ctx *gin.Context
+	w   io.Writer
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	extendWriteDeadline(w.ctx, streamWriteTimeout)
+	return w.w.Write(p)
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExtendWriteDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	// 输出时长超过服务端 WriteTimeout 的接口
+	slow := func(extend bool) gin.HandlerFunc {
+		return func(ctx *gin.Context) {
+			var w io.Writer = ctx.Writer
+			if extend {
+				w = &deadlineWriter{ctx: ctx, w: ctx.Writer}
+			}
+			for i := 0; i < 6; i++ {
+				time.Sleep(100 * time.Millisecond)
+				w.Write([]byte(strings.Repeat("x", 1024) + "\n"))
+				ctx.Writer.Flush()
+			}
+		}
+	}
+	engine.GET("/extend", slow(true))
+	engine.GET("/plain", slow(false))
+	server := httptest.NewUnstartedServer(engine)
+	server.Config.WriteTimeout = 300 * time.Millisecond
+	server.Config.ConnContext = ConnContext
+	server.Start()
+	defer server.Close()
+
+	read := func(path string) (int, error) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return len(body), err
+	}
+	if n, err := read("/extend"); err != nil || n != 6*1025 {
+		t.Fatalf("extended response: read %d bytes, err %v, want %d bytes", n, err, 6*1025)
+	}
+	// 未延长时服务端的 WriteTimeout 仍然生效
+	if n, err := read("/plain"); err == nil && n == 6*1025 {
+		t.Fatalf("response without extending the deadline should be cut off by WriteTimeout")
+	}
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"huobi/export"
+)
+
+type exportParams struct {
+	Table  string `form:"table"`  // 数据表: sections(默认), trades
+	Format string `form:"format"` // 导出格式: csv(默认), parquet
+	Start  int64  `form:"start"`  // 开始时间(单位: 秒)
+	End    int64  `form:"end"`    // 结束时间(单位: 秒), 为 0 时不限制结束时间
+}
+
+// 以文件形式下载数据, 数据边读取边输出
+func (r *registry) export(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	ps := &exportParams{Table: export.TableSections, Format: export.FormatCSV}
+	err := ctx.ShouldBindQuery(ps)
+	if err != nil {
+		fail(ctx, 400, codeInvalidParams, "%s", err)
+		return
+	}
+	options := &export.Options{Table: ps.Table, Format: ps.Format, Start: ps.Start, End: ps.End}
+	err = options.Validate()
+	if err != nil {
+		fail(ctx, 400, codeInvalidParams, "%s", err)
+		return
+	}
+	filename := options.Filename(s.Symbol)
+	if options.Format == export.FormatCSV {
+		ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		ctx.Header("Content-Type", "application/octet-stream")
+	}
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Status(200)
+	err = export.Export(&deadlineWriter{ctx: ctx, w: ctx.Writer}, s.storage, options)
+	if err != nil {
+		// 数据已开始输出, 无法再返回错误状态
+		applogger.Error("export %s failed: %s", filename, err)
+		ctx.Error(err)
+	}
+}
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"huobi/export"
+	"huobi/flow"
+	"huobi/model"
+)
+
+// 交易对健康状态
+type SymbolHealth struct {
+	Symbol    string                        `json:"symbol"`
+	Ready     bool                          `json:"ready"` // websocket 已连接且最近一次写入成功
+	Paused    bool                          `json:"paused"`
+	Websocket flow.Status                   `json:"websocket"`
+	Storage   map[string]model.WriterHealth `json:"storage"` // 按数据表区分
+}
+
+// 服务健康状态
+type Health struct {
+	Status  string          `json:"status"` // ok 或 unavailable
+	Ready   bool            `json:"ready"`  // 所有交易对均已就绪
+	Symbols []*SymbolHealth `json:"symbols"`
+}
+
+func (s *subscription) health() *SymbolHealth {
+	h := &SymbolHealth{
+		Symbol:    s.Symbol,
+		Paused:    s.isPaused(),
+		Websocket: s.client.Status(),
+		Storage:   map[string]model.WriterHealth{export.TableSections: s.writer.Health()},
+	}
+	if s.tradeWriter != nil {
+		h.Storage[export.TableTrades] = s.tradeWriter.Health()
+	}
+	h.Ready = h.Websocket.Connected
+	for _, w := range h.Storage {
+		h.Ready = h.Ready && w.OK
+	}
+	return h
+}
+
+// 已暂停的交易对不影响整体就绪状态
+func (r *registry) health() *Health {
+	subscriptions := r.list()
+	h := &Health{Ready: true, Symbols: make([]*SymbolHealth, 0, len(subscriptions))}
+	for _, s := range subscriptions {
+		sh := s.health()
+		h.Ready = h.Ready && (sh.Ready || sh.Paused)
+		h.Symbols = append(h.Symbols, sh)
+	}
+	return h
+}
+
+// 存活检查, 服务能够响应即返回 200
+func (r *registry) healthz(ctx *gin.Context) {
+	h := r.health()
+	h.Status = "ok"
+	ctx.JSON(200, h)
+}
+
+// 就绪检查, 所有交易对就绪时返回 200, 否则返回 503
+func (r *registry) readyz(ctx *gin.Context) {
+	h := r.health()
+	if h.Ready {
+		h.Status = "ok"
+		ctx.JSON(200, h)
+	} else {
+		h.Status = "unavailable"
+		ctx.JSON(503, h)
+	}
+}
@@ -0,0 +1,54 @@
+package routes
+
+import (
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"github.com/shopspring/decimal"
+	"huobi/flow"
+	"huobi/metrics"
+	"huobi/model"
+	"strconv"
+)
+
+// 监听数据区间, 每个数据流结束时生成一条数据区间交给 handle, 未监听的时长在数据区间中为 0.
+// 监听的时长可通过 client.SetDurations 修改
+func ListenSections(client *flow.Client, windows []int64, handle func(section *model.Section)) {
+	client.Listen(windows, func(price decimal.Decimal, sectionGetter flow.SectionGetter) {
+		section := &model.Section{}
+		for _, window := range sectionGetter.Durations() {
+			s := sectionGetter.GetSection(window)
+			section.SetWindow(window, s.Buy, s.Sell, s.Inflow)
+			// 各时长的结束时间相同
+			section.EndTime = s.EndTime
+			section.Partial = section.Partial || s.Partial
+		}
+		flow := sectionGetter.Flow()
+		section.BucketBuy, section.BucketSell, section.BucketInflow = flow.Buy, flow.Sell, flow.Inflow
+		section.HasBucket = true
+		section.Backfilled = flow.Backfilled
+		handle(section)
+	})
+}
+
+// 更新各时长数据区间资金净流入的监控指标
+func observeWindows(symbol string, windows []int64, section *model.Section) {
+	for _, duration := range windows {
+		window := strconv.FormatInt(duration, 10)
+		inflow, _ := section.Column("inflow" + window)
+		metrics.WindowInflow.WithLabelValues(symbol, window).Set(float64(inflow.(int64)))
+	}
+}
+
+// 监听逐笔成交
+func ListenTrades(client *flow.Client, handle func(trade *model.Trade)) {
+	client.ListenTrades(func(trades []market.Trade) {
+		for _, t := range trades {
+			handle(&model.Trade{
+				TradeId:   t.TradeId,
+				Price:     t.Price,
+				Amount:    t.Amount,
+				Direction: t.Direction,
+				Timestamp: t.Timestamp,
+			})
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"huobi/flow"
+)
+
+// 内存中的实时数据区间
+type LiveWindow struct {
+	Duration  int64 `json:"duration"` // 区间时长(单位: 秒)
+	Buy       int64 `json:"buy"`
+	Sell      int64 `json:"sell"`
+	Inflow    int64 `json:"inflow"`
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+	Partial   bool  `json:"partial"` // 累计的数据未完整覆盖区间时长
+}
+
+// 正在累计、尚未生成数据区间的数据流
+type LiveFlow struct {
+	Buy       int64 `json:"buy"`
+	Sell      int64 `json:"sell"`
+	Inflow    int64 `json:"inflow"`
+	Timestamp int64 `json:"timestamp"` // 数据流开始时间(单位: 秒), 按数据流时长对齐, 未收到成交时为 0
+}
+
+// 交易对实时数据, 直接读取订阅客户端内存, 不经过数据库
+type Live struct {
+	Symbol  string        `json:"symbol"`
+	Windows []*LiveWindow `json:"windows"`
+	Flow    *LiveFlow     `json:"flow"`  // 最新的数据流
+	Flows   []*LiveFlow   `json:"flows"` // 所有正在累计的数据流, 宽限时间内上一个数据流尚未结束时有多个
+}
+
+func newLiveFlow(f *flow.Flow) *LiveFlow {
+	return &LiveFlow{Buy: f.Buy, Sell: f.Sell, Inflow: f.Inflow, Timestamp: f.Timestamp}
+}
+
+func (r *registry) live(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	snapshot := s.client.Snapshot()
+	live := &Live{
+		Symbol:  s.Symbol,
+		Windows: make([]*LiveWindow, len(snapshot.Sections)),
+		Flow:    newLiveFlow(&snapshot.Flow),
+		Flows:   make([]*LiveFlow, len(snapshot.Flows)),
+	}
+	for i := range snapshot.Flows {
+		live.Flows[i] = newLiveFlow(&snapshot.Flows[i])
+	}
+	for i, section := range snapshot.Sections {
+		live.Windows[i] = &LiveWindow{
+			Duration:  snapshot.Durations[i],
+			Buy:       section.Buy,
+			Sell:      section.Sell,
+			Inflow:    section.Inflow,
+			StartTime: section.StartTime,
+			EndTime:   section.EndTime,
+			Partial:   section.Partial,
+		}
+	}
+	success(ctx, live)
+}
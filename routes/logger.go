@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 请求日志, 格式与 gin.Logger 相同, 查询参数中的 api_key 替换为 REDACTED, 避免 API key 写入日志
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency - param.Latency%time.Second
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactAPIKey(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// 隐藏请求路径中 api_key 查询参数的值, 其余参数保持原样
+func redactAPIKey(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	params := strings.Split(path[i+1:], "&")
+	for j, param := range params {
+		name := param
+		if k := strings.IndexByte(param, '='); k >= 0 {
+			name = param[:k]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil && unescaped == "api_key" {
+			params[j] = name + "=REDACTED"
+		}
+	}
+	return path[:i+1] + strings.Join(params, "&")
+}
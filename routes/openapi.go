@@ -0,0 +1,430 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"huobi/config"
+	"huobi/model"
+	"strconv"
+)
+
+// OpenAPI 文档节点
+type object = map[string]interface{}
+
+// 生成 OpenAPI 3 文档, 描述 RegisterRoutes 注册的全部接口.
+// 数据区间字段由 model.SectionColumns 生成, 新增字段时无需修改文档
+func OpenAPI() object {
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       "huobi flow API",
+			"description": "火币逐笔成交资金流数据接口. v1 接口统一返回 {data, meta, error} 格式, 旧版接口保留原有响应格式. 开启认证时需携带 API key, 超过限流时返回 429",
+			"version":     "1.0.0",
+		},
+		"paths": object{
+			"/openapi.json": object{
+				"get": public(operation("openapi", "OpenAPI 文档", nil, jsonResponse(object{"type": "object"}))),
+			},
+			"/healthz": object{
+				"get": public(operation("healthz", "存活检查, 服务能够响应即返回 200", nil, jsonResponse(ref("Health")))),
+			},
+			"/readyz": object{
+				"get": public(operation("readyz", "就绪检查, 所有交易对的 websocket 已连接且最近一次写入成功时返回 200", nil, object{
+					"200": object{
+						"description": "已就绪",
+						"content":     object{"application/json": object{"schema": ref("Health")}},
+					},
+					"503": object{
+						"description": "未就绪",
+						"content":     object{"application/json": object{"schema": ref("Health")}},
+					},
+				})),
+			},
+			"/subscribes": object{
+				"get": operation("legacyListSubscribes", "订阅列表(旧版)", nil, jsonResponse(arrayOf(ref("LegacySubscribe")))),
+			},
+			"/count-sections-{symbol}": object{
+				"get": operation("legacyCountSections", "数据区间总数(旧版)", []object{symbolParam}, jsonResponse(object{"type": "integer", "format": "int64"})),
+			},
+			"/sections-{symbol}": object{
+				"get": operation("legacyFindSections", "分页查询数据区间(旧版)", []object{
+					symbolParam,
+					queryParam("limit", "数量", integer),
+					queryParam("offset", "偏移量", integer),
+				}, jsonResponse(arrayOf(ref("LegacySection")))),
+			},
+			"/export-{symbol}": object{
+				"get": operation("legacyExport", "导出数据(旧版), 参数同 /api/v1/symbols/{symbol}/export", exportParameters(), exportResponse()),
+			},
+			"/writer-stats-{symbol}": object{
+				"get": operation("legacyWriterStats", "写入器状态(旧版)", []object{symbolParam}, jsonResponse(ref("WriterStatsMap"))),
+			},
+			"/metrics": object{
+				"get": operation("metrics", "Prometheus 监控指标", nil, object{
+					"200": object{
+						"description": "Prometheus 文本格式的监控指标",
+						"content":     object{"text/plain": object{"schema": object{"type": "string"}}},
+					},
+				}),
+			},
+			"/api/v1/symbols": object{
+				"get": operation("listSymbols", "订阅的交易对列表", nil, envelope(arrayOf(ref("Symbol")), false)),
+				"post": withBody(operation("addSymbol", "新增订阅并立即开始订阅, 订阅列表保存在数据库中, 重启后仍然有效(需要开启认证及 admin 权限)", nil,
+					created(envelope(ref("Symbol"), false))), ref("AddSymbol")),
+			},
+			"/api/v1/symbols/{symbol}": object{
+				"get":    operation("getSymbol", "交易对信息", []object{symbolParam}, envelope(ref("Symbol"), false)),
+				"delete": operation("removeSymbol", "删除订阅, 已保存的数据不会删除(需要开启认证及 admin 权限)", []object{symbolParam}, envelope(ref("Symbol"), false)),
+			},
+			"/api/v1/symbols/{symbol}/pause": object{
+				"post": operation("pauseSymbol", "暂停订阅, 保留内存中的数据区间(需要开启认证及 admin 权限)", []object{symbolParam}, envelope(ref("Symbol"), false)),
+			},
+			"/api/v1/symbols/{symbol}/resume": object{
+				"post": operation("resumeSymbol", "恢复订阅(需要开启认证及 admin 权限)", []object{symbolParam}, envelope(ref("Symbol"), false)),
+			},
+			"/api/v1/symbols/{symbol}/sections": object{
+				"get": operation("findSections", "查询数据区间, 按结束时间排序", sectionParameters(true), envelope(arrayOf(ref("Section")), true)),
+			},
+			"/api/v1/symbols/{symbol}/sections/count": object{
+				"get": operation("countSections", "符合条件的数据区间总数", sectionParameters(false), envelope(object{"type": "integer", "format": "int64"}, false)),
+			},
+			"/api/v1/symbols/{symbol}/export": object{
+				"get": operation("export", "以文件形式下载数据", exportParameters(), exportResponse()),
+			},
+			"/api/v1/symbols/{symbol}/writer-stats": object{
+				"get": operation("writerStats", "写入器状态, trades 仅在保存逐笔成交时存在", []object{symbolParam}, envelope(ref("WriterStatsMap"), false)),
+			},
+			"/api/v1/symbols/{symbol}/live": object{
+				"get": operation("live", "内存中的实时数据区间及正在累计的数据流, 不经过数据库", []object{symbolParam}, envelope(ref("Live"), false)),
+			},
+			"/api/v1/sections/stream": object{
+				"get": streamAuth(operation("streamSections", "以 Server-Sent Events 推送新的数据区间, 事件名为 section", streamParameters(), object{
+					"200": object{
+						"description": "事件流, 每个事件的 data 为 SectionEvent",
+						"content":     object{"text/event-stream": object{"schema": ref("SectionEvent")}},
+					},
+				})),
+			},
+			"/api/v1/sections/ws": object{
+				"get": streamAuth(operation("streamSectionsWebSocket", "以 WebSocket 推送新的数据区间, 每条文本消息为 SectionEvent", streamParameters(), object{
+					"101": object{"description": "切换为 WebSocket 协议"},
+				})),
+			},
+		},
+		"security": []object{{"bearerAuth": []string{}}, {"apiKeyHeader": []string{}}},
+		"components": object{
+			"securitySchemes": object{
+				"bearerAuth":   object{"type": "http", "scheme": "bearer"},
+				"apiKeyHeader": object{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"apiKeyQuery":  object{"type": "apiKey", "in": "query", "name": "api_key"},
+			},
+			"schemas": object{
+				"Error": object{
+					"type": "object",
+					"properties": object{
+						"code":    object{"type": "string", "enum": []string{codeInvalidParams, codeNotFound, codeConflict, codeStorage, codeUnauthorized, codeForbidden, codeRateLimited}},
+						"message": object{"type": "string"},
+					},
+				},
+				"ErrorResponse": object{
+					"type":       "object",
+					"properties": object{"error": ref("Error")},
+				},
+				"Meta": object{
+					"type": "object",
+					"properties": object{
+						"total":  integer,
+						"limit":  integer,
+						"offset": integer,
+					},
+				},
+				"Symbol": object{
+					"type": "object",
+					"properties": object{
+						"symbol":       object{"type": "string"},
+						"client_id":    object{"type": "string"},
+						"paused":       object{"type": "boolean"},
+						"bucket_size":  object{"type": "integer", "format": "int64", "description": "数据流时长(单位: 秒)"},
+						"grace_period": object{"type": "integer", "format": "int64", "description": "迟到成交的宽限时间(单位: 毫秒)"},
+						"windows": object{"type": "array", "items": object{"type": "integer", "format": "int64"},
+							"description": "生成的数据区间时长(单位: 秒), 未包含的时长在数据区间中为 0"},
+					},
+				},
+				"AddSymbol": object{
+					"type":     "object",
+					"required": []string{"symbol"},
+					"properties": object{
+						"symbol":    object{"type": "string", "pattern": config.SymbolPattern.String()},
+						"client_id": object{"type": "string", "description": "默认与交易对相同, 配置文件中存在该交易对时使用配置文件中的订阅"},
+						"paused":    object{"type": "boolean", "description": "添加后暂不订阅"},
+					},
+				},
+				"LegacySubscribe": object{
+					"type": "object",
+					"properties": object{
+						"Symbol":   object{"type": "string"},
+						"ClientId": object{"type": "string"},
+					},
+				},
+				"Section":       sectionSchema(model.SectionColumns),
+				"LegacySection": sectionSchema(legacySectionFields()),
+				"SectionEvent": object{
+					"type": "object",
+					"properties": object{
+						"symbol":  object{"type": "string"},
+						"section": ref("Section"),
+					},
+				},
+				"WriterStats": object{
+					"type": "object",
+					"properties": object{
+						"queued":   integer,
+						"capacity": integer,
+						"written":  integer,
+						"dropped":  integer,
+						"failed":   integer,
+						"spooled":  integer,
+					},
+				},
+				"WriterStatsMap": object{
+					"type": "object",
+					"properties": object{
+						"sections": ref("WriterStats"),
+						"trades":   ref("WriterStats"),
+					},
+				},
+				"WebsocketStatus": object{
+					"type": "object",
+					"properties": object{
+						"connected":       object{"type": "boolean"},
+						"connected_at":    integer,
+						"last_message_at": integer,
+						"last_ping_at":    integer,
+						"last_trade_at":   integer,
+						"reconnects":      integer,
+						"duplicates":      integer,
+					},
+				},
+				"WriterHealth": object{
+					"type": "object",
+					"properties": object{
+						"ok":              object{"type": "boolean"},
+						"last_success_at": integer,
+						"last_failure_at": integer,
+						"last_error":      object{"type": "string"},
+					},
+				},
+				"SymbolHealth": object{
+					"type": "object",
+					"properties": object{
+						"symbol":    object{"type": "string"},
+						"ready":     object{"type": "boolean"},
+						"paused":    object{"type": "boolean"},
+						"websocket": ref("WebsocketStatus"),
+						"storage": object{
+							"type":                 "object",
+							"additionalProperties": ref("WriterHealth"),
+						},
+					},
+				},
+				"Health": object{
+					"type": "object",
+					"properties": object{
+						"status":  object{"type": "string", "enum": []string{"ok", "unavailable"}},
+						"ready":   object{"type": "boolean"},
+						"symbols": arrayOf(ref("SymbolHealth")),
+					},
+				},
+				"LiveWindow": object{
+					"type": "object",
+					"properties": object{
+						"duration":   integer,
+						"buy":        integer,
+						"sell":       integer,
+						"inflow":     integer,
+						"start_time": integer,
+						"end_time":   integer,
+						"partial":    object{"type": "boolean"},
+					},
+				},
+				"LiveFlow": object{
+					"type": "object",
+					"properties": object{
+						"buy":       integer,
+						"sell":      integer,
+						"inflow":    integer,
+						"timestamp": integer,
+					},
+				},
+				"Live": object{
+					"type": "object",
+					"properties": object{
+						"symbol":  object{"type": "string"},
+						"windows": arrayOf(ref("LiveWindow")),
+						"flow":    ref("LiveFlow"),
+						"flows":   arrayOf(ref("LiveFlow")),
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *registry) openAPI(ctx *gin.Context) {
+	ctx.JSON(200, OpenAPI())
+}
+
+var integer = object{"type": "integer", "format": "int64"}
+
+var symbolParam = object{
+	"name":     "symbol",
+	"in":       "path",
+	"required": true,
+	"schema":   object{"type": "string"},
+}
+
+func ref(name string) object {
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(items object) object {
+	return object{"type": "array", "items": items}
+}
+
+func queryParam(name, description string, schema object) object {
+	return object{"name": name, "in": "query", "description": description, "schema": schema}
+}
+
+func operation(id, summary string, parameters []object, responses object) object {
+	op := object{"operationId": id, "summary": summary, "responses": responses}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	return op
+}
+
+// 请求体为 JSON 的接口
+func withBody(op object, schema object) object {
+	op["requestBody"] = object{
+		"required": true,
+		"content":  object{"application/json": object{"schema": schema}},
+	}
+	return op
+}
+
+// 成功时返回 201 的接口
+func created(responses object) object {
+	responses["201"] = responses["200"]
+	delete(responses, "200")
+	responses["409"] = responses["400"]
+	return responses
+}
+
+// 无需认证的接口
+func public(op object) object {
+	op["security"] = []object{}
+	return op
+}
+
+// 推送接口, 同时接受 api_key 查询参数
+func streamAuth(op object) object {
+	op["security"] = []object{{"bearerAuth": []string{}}, {"apiKeyHeader": []string{}}, {"apiKeyQuery": []string{}}}
+	return op
+}
+
+func jsonResponse(schema object) object {
+	return object{
+		"200": object{
+			"description": "成功",
+			"content":     object{"application/json": object{"schema": schema}},
+		},
+	}
+}
+
+// v1 接口的统一响应格式
+func envelope(data object, withMeta bool) object {
+	properties := object{"data": data}
+	if withMeta {
+		properties["meta"] = ref("Meta")
+	}
+	responses := jsonResponse(object{"type": "object", "properties": properties})
+	for _, status := range []string{"400", "401", "403", "404", "429", "500"} {
+		responses[status] = object{
+			"description": "失败",
+			"content":     object{"application/json": object{"schema": ref("ErrorResponse")}},
+		}
+	}
+	return responses
+}
+
+func sectionParameters(paging bool) []object {
+	ps := []object{
+		symbolParam,
+		queryParam("start", "结束时间不小于 start(单位: 秒)", integer),
+		queryParam("end", "结束时间小于 end(单位: 秒)", integer),
+		queryParam("fields", "返回的字段, 以逗号分隔, 默认返回全部字段", object{"type": "string"}),
+		queryParam("complete", "为 true 时只返回数据完整(partial 为 false)的数据区间", object{"type": "boolean"}),
+	}
+	if paging {
+		ps = append(ps,
+			queryParam("limit", "数量, 默认 100, 最大 1000", object{"type": "integer", "minimum": 0, "maximum": 1000}),
+			queryParam("offset", "偏移量", object{"type": "integer", "minimum": 0}),
+			queryParam("order", "按结束时间排序, 默认 asc", object{"type": "string", "enum": []string{"asc", "desc"}}),
+		)
+	}
+	return ps
+}
+
+func exportParameters() []object {
+	return []object{
+		symbolParam,
+		queryParam("table", "数据表, 默认 sections", object{"type": "string", "enum": []string{"sections", "trades"}}),
+		queryParam("format", "导出格式, 默认 csv", object{"type": "string", "enum": []string{"csv", "parquet"}}),
+		queryParam("start", "开始时间(单位: 秒)", integer),
+		queryParam("end", "结束时间(单位: 秒), 为 0 时不限制结束时间", integer),
+	}
+}
+
+func exportResponse() object {
+	file := object{"schema": object{"type": "string", "format": "binary"}}
+	responses := envelope(object{}, false)
+	responses["200"] = object{
+		"description": "数据文件",
+		"content": object{
+			"text/csv":                 file,
+			"application/octet-stream": file,
+		},
+	}
+	return responses
+}
+
+func streamParameters() []object {
+	return []object{
+		queryParam("symbols", "订阅的交易对, 以逗号分隔, 默认全部交易对", object{"type": "string"}),
+		queryParam("fields", "推送的字段, 以逗号分隔, 默认全部字段", object{"type": "string"}),
+	}
+}
+
+func sectionSchema(fields []string) object {
+	properties := make(object, len(fields))
+	for _, field := range fields {
+		properties[field] = integer
+	}
+	// 数据区间未完整覆盖及包含补全的成交时为 true
+	for _, field := range []string{"partial", "Partial", "backfilled", "Backfilled"} {
+		if _, ok := properties[field]; ok {
+			properties[field] = object{"type": "boolean"}
+		}
+	}
+	return object{"type": "object", "properties": properties}
+}
+
+// 旧版接口直接输出 model.Section, 字段名与结构体字段名一致
+func legacySectionFields() []string {
+	fields := []string{"ID", "EndTime"}
+	for _, duration := range config.SupportedWindows {
+		for _, prefix := range []string{"Buy", "Sell", "Inflow"} {
+			fields = append(fields, prefix+strconv.FormatInt(duration, 10))
+		}
+	}
+	return append(fields, "BucketBuy", "BucketSell", "BucketInflow", "Partial", "Backfilled")
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	config2 "github.com/morgine/pkg/config"
+	"huobi/config"
+	"reflect"
+	"strings"
+)
+
+// 修改后需要重启才能生效的配置
+var restartNamespaces = []string{"sqlite", "postgres", "gorm", "writer", "auth", "cors"}
+
+// 重新加载订阅配置: 新增及删除订阅, 修改数据区间时长时保留其余时长已累计的数据,
+// 修改 client id、数据流时长、存储或行情连接配置时重新创建该订阅, 其余交易对不受影响.
+// 配置有误时不做任何修改, 部分订阅创建失败时返回的 error 包含所有失败的交易对
+func (r *registry) reload(configs config2.Configs) error {
+	storageConfig, err := config.NewStorage("storage", configs)
+	if err != nil {
+		return err
+	}
+	marketConfig, err := config.NewMarket("market", configs)
+	if err != nil {
+		return err
+	}
+	subscribes, err := config.NewSubscribes(configs, storageConfig)
+	if err != nil {
+		return err
+	}
+	states, err := r.shared.ListSubscriptionStates()
+	if err != nil {
+		return err
+	}
+
+	r.adminMu.Lock()
+	defer r.adminMu.Unlock()
+	for _, namespace := range restartNamespaces {
+		if !reflect.DeepEqual(r.configs[namespace], configs[namespace]) {
+			applogger.Warn("config [%s] changed, restart to apply", namespace)
+		}
+	}
+	r.configs = configs
+	r.storageConfig = storageConfig
+	r.marketConfig = marketConfig
+
+	var enabled []*subscribeState
+	pending := make(map[string]*subscribeState)
+	disabled := make(map[string]bool)
+	for _, state := range mergeSubscribes(subscribes, states, storageConfig, marketConfig) {
+		if !state.Enabled {
+			disabled[state.Symbol] = true
+			continue
+		}
+		enabled = append(enabled, state)
+		pending[state.Symbol] = state
+	}
+	r.disabled = disabled
+
+	for _, s := range r.list() {
+		state, ok := pending[s.Symbol]
+		switch {
+		case !ok:
+			r.remove(s)
+			s.close()
+			applogger.Info("symbol %s removed by config reload", s.Symbol)
+		case !sameSubscription(&s.Subscribe, &state.Subscribe):
+			// 保留在 pending 中, 稍后重新创建
+			r.remove(s)
+			s.close()
+			applogger.Info("symbol %s changed by config reload, windows are reset", s.Symbol)
+		default:
+			delete(pending, s.Symbol)
+			if !reflect.DeepEqual(s.currentWindows(), state.Windows) {
+				s.setWindows(state.Windows)
+				applogger.Info("symbol %s windows changed to %v", s.Symbol, state.Windows)
+			}
+		}
+	}
+
+	var errs []string
+	for _, state := range enabled {
+		if pending[state.Symbol] == nil {
+			continue
+		}
+		s, err := r.newSubscription(state.Subscribe, true)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		s.paused = state.Paused
+		r.add(s)
+		s.start()
+		applogger.Info("symbol %s subscribed by config reload", s.Symbol)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("routes: reload: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// 订阅的 client id、数据流时长、存储及行情连接配置是否相同, 不同时需要重新创建订阅
+func sameSubscription(a, b *config.Subscribe) bool {
+	return a.ClientId == b.ClientId && a.BucketSize == b.BucketSize && a.GracePeriod == b.GracePeriod && *a.Storage == *b.Storage &&
+		reflect.DeepEqual(a.Market, b.Market)
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+)
+
+// 接口统一响应格式, 成功时包含 data, 失败时包含 error
+type Response struct {
+	Data  interface{} `json:"data,omitempty"`
+	Meta  *Meta       `json:"meta,omitempty"`
+	Error *Error      `json:"error,omitempty"`
+}
+
+// 分页信息
+type Meta struct {
+	Total  int64 `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// 错误码
+const (
+	codeInvalidParams = "invalid_params"
+	codeNotFound      = "not_found"
+	codeStorage       = "storage_error"
+)
+
+func success(ctx *gin.Context, data interface{}) {
+	ctx.JSON(200, &Response{Data: data})
+}
+
+func successList(ctx *gin.Context, data interface{}, meta *Meta) {
+	ctx.JSON(200, &Response{Data: data, Meta: meta})
+}
+
+func fail(ctx *gin.Context, status int, code, format string, args ...interface{}) {
+	ctx.AbortWithStatusJSON(status, &Response{Error: &Error{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
@@ -1,115 +1,250 @@
 package routes
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
 	config2 "github.com/morgine/pkg/config"
-	"github.com/shopspring/decimal"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"huobi/config"
-	"huobi/flow"
 	"huobi/model"
+	"sync"
 )
 
-func RegisterRoutes(engine *gin.Engine, configs config2.Configs) []*flow.Client {
+// 注册路由, subscribe 用于开始订阅所有未暂停的交易对, reload 用于重新加载配置文件中的订阅,
+// closeFunc 用于关闭订阅并在写入剩余数据后释放存储
+func RegisterRoutes(engine *gin.Engine, configs config2.Configs) (subscribe func(), reload func(configs config2.Configs) error, closeFunc func()) {
 
-	cfg := &config.Server{}
-	err := configs.UnmarshalSub("server", cfg)
+	writerConfig, err := config.NewWriter("writer", configs)
 	if err != nil {
 		panic(err)
 	}
 
-	subscribes := cfg.InitSubscribes()
-	engine.GET("/subscribes", func(ctx *gin.Context) {
-		ctx.JSON(200, subscribes)
-	})
+	storageConfig, err := config.NewStorage("storage", configs)
+	if err != nil {
+		panic(err)
+	}
+
+	marketConfig, err := config.NewMarket("market", configs)
+	if err != nil {
+		panic(err)
+	}
+
+	subscribes, err := config.NewSubscribes(configs, storageConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	authConfig, err := config.NewAuth("auth", configs)
+	if err != nil {
+		panic(err)
+	}
 
-	var clients []*flow.Client
-	for _, subscribe := range subscribes {
-		gorm, err := config.NewPostgresORM("postgres", "gorm", subscribe.Symbol, configs)
+	corsConfig, err := config.NewCors("cors", configs)
+	if err != nil {
+		panic(err)
+	}
+
+	r := &registry{
+		hub:           newHub(),
+		upgrader:      newUpgrader(corsConfig),
+		shared:        newCheckedSharedStorage(storageConfig, configs),
+		storageConfig: storageConfig,
+		marketConfig:  marketConfig,
+		writerConfig:  writerConfig,
+		configs:       configs,
+		disabled:      make(map[string]bool),
+	}
+	if authConfig.Enabled {
+		if storageConfig.Driver == config.StorageMemory {
+			panic("routes: api key authentication requires a persistent storage driver")
+		}
+		r.auth = newAuthenticator(authConfig, r.shared)
+	} else {
+		applogger.Warn("api key authentication is disabled, admin endpoints are not registered")
+	}
+	states, err := r.shared.ListSubscriptionStates()
+	if err != nil {
+		panic(err)
+	}
+	for _, state := range mergeSubscribes(subscribes, states, storageConfig, marketConfig) {
+		if !state.Enabled {
+			r.disabled[state.Symbol] = true
+			applogger.Info("symbol %s is disabled", state.Symbol)
+			continue
+		}
+		s, err := r.newSubscription(state.Subscribe, false)
 		if err != nil {
 			panic(err)
 		}
-		db := model.NewDB(gorm)
-
-		client := flow.NewClient(subscribe.ClientId, subscribe.Symbol, 10)
-
-		client.Listen([]int64{10, 30, 60, 300, 900, 3600, 14400}, func(price decimal.Decimal, sectionGetter flow.SectionGetter) {
-			var section = &model.Section{
-				ID:          0,
-				Buy10:       0,
-				Sell10:      0,
-				Inflow10:    0,
-				Buy30:       0,
-				Sell30:      0,
-				Inflow30:    0,
-				Buy60:       0,
-				Sell60:      0,
-				Inflow60:    0,
-				Buy300:      0,
-				Sell300:     0,
-				Inflow300:   0,
-				Buy900:      0,
-				Sell900:     0,
-				Inflow900:   0,
-				Buy3600:     0,
-				Sell3600:    0,
-				Inflow3600:  0,
-				Buy14400:    0,
-				Sell14400:   0,
-				Inflow14400: 0,
-				EndTime:     0,
-			}
-			s := sectionGetter.GetSection(10)
-			section.Buy10 = s.Buy
-			section.Sell10 = s.Sell
-			section.Inflow10 = s.Inflow
-			section.EndTime = s.EndTime
-			s = sectionGetter.GetSection(30)
-			section.Buy30 = s.Buy
-			section.Sell30 = s.Sell
-			section.Inflow30 = s.Inflow
-			s = sectionGetter.GetSection(60)
-			section.Buy60 = s.Buy
-			section.Sell60 = s.Sell
-			section.Inflow60 = s.Inflow
-			s = sectionGetter.GetSection(300)
-			section.Buy300 = s.Buy
-			section.Sell300 = s.Sell
-			section.Inflow300 = s.Inflow
-			s = sectionGetter.GetSection(900)
-			section.Buy900 = s.Buy
-			section.Sell900 = s.Sell
-			section.Inflow900 = s.Inflow
-			s = sectionGetter.GetSection(3600)
-			section.Buy3600 = s.Buy
-			section.Sell3600 = s.Sell
-			section.Inflow3600 = s.Inflow
-			s = sectionGetter.GetSection(14400)
-			section.Buy14400 = s.Buy
-			section.Sell14400 = s.Sell
-			section.Inflow14400 = s.Inflow
-			db.CreateSection(section)
-		})
-
-		clients = append(clients, client)
-
-		engine.GET("/count-sections-"+subscribe.Symbol, func(ctx *gin.Context) {
-			ctx.JSON(200, db.CountSection())
-		})
-
-		{
-			type params struct {
-				Limit, Offset int
-			}
-			engine.GET("/sections-"+subscribe.Symbol, func(ctx *gin.Context) {
-				ps := &params{}
-				err := ctx.Bind(&ps)
-				if err != nil {
-					ctx.Error(err)
-				} else {
-					ctx.JSON(200, db.FindSections(ps.Limit, ps.Offset))
-				}
-			})
+		s.paused = state.Paused
+		r.subscriptions = append(r.subscriptions, s)
+	}
+
+	// 无需认证的接口
+	engine.GET("/openapi.json", r.openAPI)
+	engine.GET("/healthz", r.healthz)
+	engine.GET("/readyz", r.readyz)
+
+	read := engine.Group("", r.auth.require(model.ScopeRead))
+
+	read.GET("/subscribes", r.listSubscribesLegacy)
+
+	// 旧版接口, 保留原有的响应格式
+	read.GET("/count-sections-:symbol", r.countSectionsLegacy)
+	read.GET("/sections-:symbol", r.findSectionsLegacy)
+	read.GET("/export-:symbol", r.export)
+	read.GET("/writer-stats-:symbol", r.writerStatsLegacy)
+
+	registerAPI(read.Group("/api/v1"), r)
+	// 推送接口同时接受 api_key 查询参数
+	stream := engine.Group("/api/v1", r.auth.requireStream(model.ScopeRead))
+	stream.GET("/sections/stream", r.streamSSE)
+	stream.GET("/sections/ws", r.streamWebSocket)
+	registerAdmin(engine.Group("/api/v1"), r)
+
+	read.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	subscribe = func() {
+		for _, s := range r.list() {
+			s.start()
+		}
+	}
+	reload = r.reload
+	closeFunc = func() {
+		for _, s := range r.list() {
+			s.close()
 		}
+		r.shared.Close()
+	}
+	return subscribe, reload, closeFunc
+}
+
+// 创建共享存储并检查数据库结构, 未迁移时 panic
+func newCheckedSharedStorage(storageConfig *config.Storage, configs config2.Configs) model.SharedStorage {
+	shared, err := NewSharedStorage(storageConfig, configs)
+	if err != nil {
+		panic(err)
+	}
+	if schema, ok := shared.(model.Schema); ok {
+		err = schema.CheckSchema()
+		if err != nil {
+			shared.Close()
+			panic(fmt.Errorf("shared storage: %w, run \"migrate up\" first", err))
+		}
+	}
+	return shared
+}
+
+// 所有交易对订阅, 可通过管理接口在运行时增删
+type registry struct {
+	subscriptions []*subscription
+	mu            sync.RWMutex
+	adminMu       sync.Mutex // 管理操作及重新加载配置依次执行
+	hub           *hub
+	auth          *authenticator // 未开启认证时为 nil
+	upgrader      *websocket.Upgrader
+	shared        model.SharedStorage
+	storageConfig *config.Storage
+	marketConfig  *config.Market
+	writerConfig  *config.Writer
+	configs       config2.Configs
+	disabled      map[string]bool // 配置文件中未启用的交易对
+}
+
+// 所有订阅的副本
+func (r *registry) list() []*subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*subscription(nil), r.subscriptions...)
+}
+
+func (r *registry) add(s *subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscriptions = append(r.subscriptions, s)
+}
+
+func (r *registry) remove(s *subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, sub := range r.subscriptions {
+		if sub == s {
+			r.subscriptions = append(r.subscriptions[:i:i], r.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *registry) get(symbol string) *subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.subscriptions {
+		if s.Symbol == symbol {
+			return s
+		}
+	}
+	return nil
+}
+
+// 获取路径参数中交易对的订阅, 交易对不存在时返回 404
+func (r *registry) lookup(ctx *gin.Context) (*subscription, bool) {
+	symbol := ctx.Param("symbol")
+	s := r.get(symbol)
+	if s == nil {
+		fail(ctx, 404, codeNotFound, "symbol %s is not subscribed", symbol)
+		return nil, false
+	}
+	return s, true
+}
+
+// 旧版订阅信息
+type legacySubscribe struct {
+	Symbol   string
+	ClientId string
+}
+
+func (r *registry) listSubscribesLegacy(ctx *gin.Context) {
+	subscriptions := r.list()
+	subscribes := make([]legacySubscribe, len(subscriptions))
+	for i, s := range subscriptions {
+		subscribes[i] = legacySubscribe{Symbol: s.Symbol, ClientId: s.ClientId}
+	}
+	ctx.JSON(200, subscribes)
+}
+
+func (r *registry) countSectionsLegacy(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	total, err := s.storage.CountSection()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(200, total)
+}
+
+func (r *registry) findSectionsLegacy(ctx *gin.Context) {
+	s, ok := r.lookup(ctx)
+	if !ok {
+		return
+	}
+	type params struct {
+		Limit, Offset int
+	}
+	ps := &params{}
+	err := ctx.ShouldBindQuery(ps)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	sections, err := s.storage.FindSections(ps.Limit, ps.Offset)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
-	return clients
+	ctx.JSON(200, sections)
 }
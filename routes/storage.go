@@ -0,0 +1,50 @@
+package routes
+
+import (
+	config2 "github.com/morgine/pkg/config"
+	"huobi/config"
+	"huobi/model"
+)
+
+// 根据存储配置创建交易对的存储
+func NewStorage(cfg *config.Storage, symbol string, configs config2.Configs) (model.Storage, error) {
+	switch cfg.Driver {
+	case config.StorageSqlite:
+		gorm, err := config.NewSqliteORM("sqlite", "gorm", symbol, configs)
+		if err != nil {
+			return nil, err
+		}
+		return model.NewDB(gorm), nil
+	case config.StorageMemory:
+		return model.NewMemoryStorage(), nil
+	default:
+		gorm, err := config.NewPostgresORM("postgres", "gorm", symbol, configs)
+		if err != nil {
+			return nil, err
+		}
+		return model.NewDB(gorm), nil
+	}
+}
+
+// 共享数据表名前缀, 与交易对数据表区分
+const sharedPrefix = config.SharedPrefix
+
+// 根据存储配置创建不区分交易对的共享存储
+func NewSharedStorage(cfg *config.Storage, configs config2.Configs) (model.SharedStorage, error) {
+	switch cfg.Driver {
+	case config.StorageSqlite:
+		gorm, err := config.NewSqliteORM("sqlite", "gorm", sharedPrefix, configs)
+		if err != nil {
+			return nil, err
+		}
+		return model.NewSharedDB(gorm), nil
+	case config.StorageMemory:
+		return model.NewMemorySharedStorage(), nil
+	default:
+		gorm, err := config.NewPostgresORM("postgres", "gorm", sharedPrefix, configs)
+		if err != nil {
+			return nil, err
+		}
+		return model.NewSharedDB(gorm), nil
+	}
+}
@@ -0,0 +1,228 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"huobi/config"
+	"huobi/model"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamBufferSize   = 256              // 每个客户端的推送缓冲区大小
+	streamHeartbeat    = 15 * time.Second // 心跳间隔
+	streamWriteTimeout = 10 * time.Second // 推送及导出接口单次写入超时时间
+)
+
+// 推送给客户端的数据区间
+type sectionEvent struct {
+	symbol  string
+	section model.Section
+}
+
+// 按客户端选择的字段生成推送数据
+func (e *sectionEvent) render(columns []string) gin.H {
+	data := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		data[column], _ = e.section.Column(column)
+	}
+	return gin.H{"symbol": e.symbol, "section": data}
+}
+
+// 推送客户端
+type streamClient struct {
+	symbols map[string]bool // 订阅的交易对, 为空时订阅全部交易对
+	columns []string        // 推送的字段
+	events  chan *sectionEvent
+	dropped int           // 连续丢弃的数据量
+	lagged  chan struct{} // 客户端接收过慢被断开时关闭
+}
+
+// 数据区间推送中心. 发布操作不会阻塞订阅协程, 客户端缓冲区已满时丢弃数据,
+// 连续丢弃数据量达到缓冲区大小的客户端将被断开
+type hub struct {
+	clients map[*streamClient]struct{}
+	mu      sync.Mutex
+}
+
+func newHub() *hub {
+	return &hub{clients: make(map[*streamClient]struct{})}
+}
+
+func (h *hub) subscribe(symbols, columns []string) *streamClient {
+	c := &streamClient{
+		symbols: make(map[string]bool, len(symbols)),
+		columns: columns,
+		events:  make(chan *sectionEvent, streamBufferSize),
+		lagged:  make(chan struct{}),
+	}
+	for _, symbol := range symbols {
+		c.symbols[symbol] = true
+	}
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+func (h *hub) unsubscribe(c *streamClient) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
+
+// 发布数据区间, section 为副本, 避免与写入器同时修改
+func (h *hub) publish(symbol string, section model.Section) {
+	event := &sectionEvent{symbol: symbol, section: section}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if len(c.symbols) > 0 && !c.symbols[symbol] {
+			continue
+		}
+		select {
+		case c.events <- event:
+			c.dropped = 0
+		default:
+			c.dropped++
+			if c.dropped >= streamBufferSize {
+				delete(h.clients, c)
+				close(c.lagged)
+			}
+		}
+	}
+}
+
+// 解析推送参数: symbols 为以逗号分隔的交易对, fields 为以逗号分隔的字段, 均默认为全部
+func (r *registry) bindStream(ctx *gin.Context) (symbols, columns []string, ok bool) {
+	if s := ctx.Query("symbols"); s != "" {
+		for _, symbol := range strings.Split(s, ",") {
+			symbol = strings.TrimSpace(symbol)
+			if r.get(symbol) == nil {
+				fail(ctx, 400, codeInvalidParams, "symbol %s is not subscribed", symbol)
+				return nil, nil, false
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+	if s := ctx.Query("fields"); s != "" {
+		for _, field := range strings.Split(s, ",") {
+			field = strings.TrimSpace(field)
+			if !isSectionColumn(field) {
+				fail(ctx, 400, codeInvalidParams, "unknown field %q", field)
+				return nil, nil, false
+			}
+			columns = append(columns, field)
+		}
+	} else {
+		columns = model.SectionColumns
+	}
+	return symbols, columns, true
+}
+
+// 以 Server-Sent Events 推送新的数据区间
+func (r *registry) streamSSE(ctx *gin.Context) {
+	symbols, columns, ok := r.bindStream(ctx)
+	if !ok {
+		return
+	}
+	c := r.hub.subscribe(symbols, columns)
+	defer r.hub.unsubscribe(c)
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		w = &deadlineWriter{ctx: ctx, w: w}
+		select {
+		case event := <-c.events:
+			extendWriteDeadline(ctx, streamWriteTimeout)
+			ctx.SSEvent("section", event.render(c.columns))
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case <-c.lagged:
+			return false
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// 创建 WebSocket 升级器, 来源检查与跨域配置一致, 未携带 Origin 的非浏览器客户端不做检查
+func newUpgrader(cors *config.Cors) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || cors.AllowAll() {
+				return true
+			}
+			for _, allowed := range cors.AllowOrigins {
+				if allowed == origin {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// 以 WebSocket 推送新的数据区间, 每条消息为一个 JSON 对象
+func (r *registry) streamWebSocket(ctx *gin.Context) {
+	symbols, columns, ok := r.bindStream(ctx)
+	if !ok {
+		return
+	}
+	conn, err := r.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// Upgrade 已返回错误响应
+		return
+	}
+	defer conn.Close()
+	c := r.hub.subscribe(symbols, columns)
+	defer r.hub.unsubscribe(c)
+
+	// 读取客户端消息以处理 pong 及关闭, 客户端超过两个心跳周期无响应视为断开
+	closed := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event := <-c.events:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if conn.WriteJSON(event.render(c.columns)) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)) != nil {
+				return
+			}
+		case <-c.lagged:
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client is too slow"), time.Now().Add(streamWriteTimeout))
+			return
+		case <-closed:
+			return
+		}
+	}
+}
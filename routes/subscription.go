@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"huobi/config"
+	"huobi/export"
+	"huobi/flow"
+	"huobi/metrics"
+	"huobi/model"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 交易对订阅, 包含订阅客户端、存储及写入器
+type subscription struct {
+	config.Subscribe
+	client      *flow.Client
+	storage     model.Storage
+	writer      *model.Writer
+	tradeWriter *model.Writer // 未开启保存逐笔成交时为 nil
+	paused      bool
+	unsubscribe func() // 未订阅时为 nil
+	mu          sync.Mutex
+	windowsMu   sync.RWMutex // 保护 Windows, 重新加载配置时可修改
+}
+
+// 创建交易对的存储、写入器及订阅客户端, 新的数据区间同时发布到 hub, 返回前不会开始订阅.
+// migrate 为 true 时先执行数据库迁移, 用于运行时新增的交易对
+func (r *registry) newSubscription(subscribe config.Subscribe, migrate bool) (*subscription, error) {
+	storageConfig, writerConfig, hub := subscribe.Storage, r.writerConfig, r.hub
+	db, err := NewStorage(storageConfig, subscribe.Symbol, r.configs)
+	if err != nil {
+		return nil, err
+	}
+	// 拒绝在未迁移的数据库上运行
+	if schema, ok := db.(model.SchemaStorage); ok {
+		if migrate {
+			_, err = schema.MigrateUp()
+			if err != nil {
+				db.Close()
+				return nil, fmt.Errorf("symbol %s: %w", subscribe.Symbol, err)
+			}
+		}
+		err = schema.CheckSchema()
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("symbol %s: %w, run \"migrate up\" first", subscribe.Symbol, err)
+		}
+	}
+	spool, err := model.OpenSectionSpool(writerConfig.SpoolFile(subscribe.Symbol, "sections"))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &subscription{Subscribe: subscribe, storage: db}
+	s.writer = model.NewSectionWriter(db, &model.WriterOptions{
+		Symbol:        subscribe.Symbol,
+		Table:         export.TableSections,
+		QueueSize:     writerConfig.QueueSize,
+		BatchSize:     writerConfig.BatchSize,
+		FlushInterval: writerConfig.Interval(),
+		Spool:         spool,
+	})
+
+	if storageConfig.Trades {
+		tradeSpool, err := model.OpenTradeSpool(writerConfig.SpoolFile(subscribe.Symbol, "trades"))
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.tradeWriter = model.NewTradeWriter(db, &model.WriterOptions{
+			Symbol:        subscribe.Symbol,
+			Table:         export.TableTrades,
+			QueueSize:     writerConfig.QueueSize,
+			BatchSize:     writerConfig.BatchSize,
+			FlushInterval: writerConfig.Interval(),
+			Spool:         tradeSpool,
+		})
+	}
+
+	s.client = flow.NewClient(subscribe.ClientId, subscribe.Symbol, subscribe.BucketSize)
+	s.client.SetEndpoint(&flow.Endpoint{
+		URL:           subscribe.Market.URL(),
+		PingTimeout:   subscribe.Market.PingTimeoutDuration(),
+		PongTimeout:   subscribe.Market.PongTimeoutDuration(),
+		ReconnectMin:  subscribe.Market.ReconnectMinDuration(),
+		ReconnectMax:  subscribe.Market.ReconnectMaxDuration(),
+		AutoReconnect: subscribe.Market.Reconnect(),
+	})
+	s.client.SetGracePeriod(time.Duration(subscribe.GracePeriod) * time.Millisecond)
+	if subscribe.Market.BackfillEnabled() {
+		s.client.SetBackfill(&flow.Backfill{
+			URL:     subscribe.Market.RestURL,
+			Size:    subscribe.Market.BackfillSize,
+			Timeout: subscribe.Market.PongTimeoutDuration(),
+			TradeId: subscribe.Market.BackfillOnTradeIdGap(),
+		})
+	}
+	if s.tradeWriter != nil {
+		ListenTrades(s.client, func(trade *model.Trade) {
+			s.tradeWriter.Write(trade)
+		})
+	}
+	ListenSections(s.client, subscribe.Windows, func(section *model.Section) {
+		observeWindows(subscribe.Symbol, s.currentWindows(), section)
+		hub.publish(subscribe.Symbol, *section)
+		s.writer.Write(section)
+	})
+	// 恢复失败时从空数据区间开始累计, 不影响订阅
+	err = s.warmUp()
+	if err != nil {
+		applogger.Warn("symbol %s warm up failed: %s", subscribe.Symbol, err)
+	}
+	return s, nil
+}
+
+// 当前监听的数据区间时长
+func (s *subscription) currentWindows() []int64 {
+	s.windowsMu.RLock()
+	defer s.windowsMu.RUnlock()
+	return s.Windows
+}
+
+// 修改数据区间时长, 保留未修改的时长已累计的数据
+func (s *subscription) setWindows(windows []int64) {
+	s.windowsMu.Lock()
+	old := s.Windows
+	s.Windows = windows
+	s.windowsMu.Unlock()
+	s.client.SetDurations(windows)
+	for _, window := range old {
+		if !containsWindow(windows, window) {
+			metrics.WindowInflow.DeleteLabelValues(s.Symbol, strconv.FormatInt(window, 10))
+		}
+	}
+}
+
+func containsWindow(windows []int64, window int64) bool {
+	for _, w := range windows {
+		if w == window {
+			return true
+		}
+	}
+	return false
+}
+
+// 写入器状态
+func (s *subscription) writerStats() map[string]*model.WriterStats {
+	stats := map[string]*model.WriterStats{"sections": s.writer.Stats()}
+	if s.tradeWriter != nil {
+		stats["trades"] = s.tradeWriter.Stats()
+	}
+	return stats
+}
+
+// 开始订阅, 已暂停或已订阅时不做处理
+func (s *subscription) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused && s.unsubscribe == nil {
+		s.unsubscribe = s.client.Subscribe()
+	}
+}
+
+// 停止订阅, 保留内存中的数据区间
+func (s *subscription) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+		s.unsubscribe = nil
+	}
+}
+
+// 暂停或恢复订阅
+func (s *subscription) setPaused(paused bool) {
+	s.mu.Lock()
+	s.paused = paused
+	s.mu.Unlock()
+	if paused {
+		s.stop()
+	} else {
+		s.start()
+	}
+}
+
+func (s *subscription) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// 停止订阅, 写入剩余数据并释放存储
+func (s *subscription) close() {
+	s.stop()
+	s.writer.Close()
+	if s.tradeWriter != nil {
+		s.tradeWriter.Close()
+	}
+	err := s.storage.Close()
+	if err != nil {
+		applogger.Error("close storage %s failed: %s", s.Symbol, err)
+	}
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"github.com/huobirdcenter/huobi_golang/logging/applogger"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"huobi/flow"
+	"huobi/model"
+	"time"
+)
+
+// 使用已保存的数据恢复订阅客户端的数据区间, 避免重启后各时长从空数据区间开始累计.
+// 优先使用逐笔成交, 未保存成交时使用数据区间中的数据流, 恢复的数据不会重复写入存储
+func (s *subscription) warmUp() error {
+	var longest int64
+	for _, window := range s.Windows {
+		if window > longest {
+			longest = window
+		}
+	}
+	// 最近结束的数据流在宽限时间及两个数据流时长之前开始, 数据区间需包含其之前 longest 秒的数据流才完整
+	since := time.Now().Unix() - longest - 3*s.BucketSize
+	if s.Storage.Trades {
+		var trades []market.Trade
+		err := s.storage.EachTrade(since*1000, 0, func(t *model.Trade) error {
+			trades = append(trades, market.Trade{
+				TradeId:   t.TradeId,
+				Price:     t.Price,
+				Amount:    t.Amount,
+				Direction: t.Direction,
+				Timestamp: t.Timestamp,
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(trades) > 0 {
+			s.client.WarmTrades(trades)
+			applogger.Info("symbol %s warmed up from %d trades", s.Symbol, len(trades))
+			return nil
+		}
+	}
+	var flows []*flow.Flow
+	err := s.storage.EachSection(since, 0, func(section *model.Section) error {
+		// 未保存数据流的数据区间跳过, 缺少的数据流使数据区间标记为不完整
+		if !section.HasBucket {
+			return nil
+		}
+		flows = append(flows, &flow.Flow{
+			Buy:       section.BucketBuy,
+			Sell:      section.BucketSell,
+			Inflow:    section.BucketInflow,
+			Timestamp: section.EndTime,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(flows) > 0 {
+		s.client.WarmFlows(flows)
+		applogger.Info("symbol %s warmed up from %d sections", s.Symbol, len(flows))
+	}
+	return nil
+}
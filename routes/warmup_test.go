@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"huobi/config"
+	"huobi/flow"
+	"huobi/model"
+)
+
+func TestWarmUpFromSections(t *testing.T) {
+	// 最近结束的数据流, 按数据流时长对齐
+	last := time.Now().Unix()/10*10 - 10
+	tests := []struct {
+		name        string
+		hasBucket   []bool // 从 last-50 至 last 的数据区间是否保存了数据流
+		wantBuy     int64
+		wantPartial bool
+	}{
+		{name: "all rows", hasBucket: []bool{true, true, true, true, true, true}, wantBuy: 40},
+		// 迁移前写入的数据区间的数据流为 0, 不能视为空数据流
+		{name: "rows before migration", hasBucket: []bool{false, false, true, true, true, true}, wantBuy: 40},
+		{name: "row without bucket in between", hasBucket: []bool{true, true, true, false, true, true}, wantBuy: 30, wantPartial: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := model.NewMemoryStorage()
+			for i, hasBucket := range test.hasBucket {
+				section := &model.Section{EndTime: last - 50 + int64(i)*10, HasBucket: hasBucket}
+				if hasBucket {
+					section.BucketBuy, section.BucketInflow = 10, 10
+				}
+				storage.CreateSections([]*model.Section{section})
+			}
+			s := &subscription{
+				Subscribe: config.Subscribe{
+					Symbol:     "testusdt",
+					BucketSize: 10,
+					Windows:    []int64{30},
+					Storage:    &config.Storage{Driver: "memory"},
+				},
+				storage: storage,
+				client:  flow.NewClient("testusdt", "testusdt", 10),
+			}
+			ListenSections(s.client, s.Windows, func(section *model.Section) {})
+			err := s.warmUp()
+			if err != nil {
+				t.Fatal(err)
+			}
+			section := s.client.Snapshot().Sections[0]
+			if section.EndTime != last || section.Buy != test.wantBuy || section.Partial != test.wantPartial {
+				t.Fatalf("section = %+v, want EndTime %d, Buy %d, Partial %v", section, last, test.wantBuy, test.wantPartial)
+			}
+		})
+	}
+}